@@ -0,0 +1,158 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ShortenerServiceSpec defines the desired state of ShortenerService.
+// +kubebuilder:validation:XValidation:rule="!has(self.port) || !has(self.grpcPort) || self.port != self.grpcPort",message="port and grpcPort must differ"
+type ShortenerServiceSpec struct {
+	// Image is the container image of the shortener API.
+	// +kubebuilder:default="docker.io/sadegh81/url-shortener:v3"
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Replicas is the number of shortener API pods. Each pod keeps its own
+	// links, in memory or on its volume, so there can be at most one until
+	// the shortener supports shared storage.
+	// +kubebuilder:default=1
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Resources are the compute resources of the shortener API container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Storage keeps the links in a database on a PersistentVolumeClaim.
+	// Links are kept in memory and lost on restart when unset.
+	// +optional
+	Storage *ShortenerStorage `json:"storage,omitempty"`
+
+	// PublicURL is the externally reachable base URL of the shortener, e.g.
+	// "https://sho.rt". It is used to report the full short link on
+	// ShortURLs.
+	// +optional
+	PublicURL string `json:"publicURL,omitempty"`
+
+	// Port is the port the Service exposes the shortener API on.
+	// +kubebuilder:default=8080
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// GRPCPort is the port the Service exposes the gRPC API of the shortener
+	// on.
+	// +kubebuilder:default=50051
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	GRPCPort int32 `json:"grpcPort,omitempty"`
+}
+
+// ShortenerStorage describes the PersistentVolumeClaim of a shortener.
+type ShortenerStorage struct {
+	// Size is the requested size of the claim, e.g. "1Gi".
+	Size resource.Quantity `json:"size"`
+
+	// StorageClassName is the storage class of the claim. The cluster
+	// default is used when empty.
+	// +optional
+	StorageClassName string `json:"storageClassName,omitempty"`
+}
+
+// ShortenerServiceStatus defines the observed state of ShortenerService.
+type ShortenerServiceStatus struct {
+	// URL is the in-cluster address of the shortener API.
+	URL string `json:"url,omitempty"`
+
+	// GRPCAddress is the in-cluster host:port of the gRPC API of the
+	// shortener.
+	GRPCAddress string `json:"grpcAddress,omitempty"`
+
+	// APIKeySecret is the name of the Secret holding the API key that the
+	// management routes of the shortener require, under the key "api-key".
+	APIKeySecret string `json:"apiKeySecret,omitempty"`
+
+	// ReadyReplicas is the number of shortener API pods that are ready.
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Conditions describe the latest observations of the shortener's state.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+// ShortenerServiceReference points a ShortURL at a ShortenerService in the
+// same namespace.
+type ShortenerServiceReference struct {
+	// Name of the ShortenerService.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// Reasons reported on ShortenerService conditions.
+const (
+	// ReasonDeploymentAvailable means the shortener API has ready pods.
+	ReasonDeploymentAvailable = "DeploymentAvailable"
+	// ReasonDeploymentUnavailable means no shortener API pod is ready yet.
+	ReasonDeploymentUnavailable = "DeploymentUnavailable"
+	// ReasonServiceNotFound means a ShortURL references a ShortenerService
+	// that does not exist.
+	ReasonServiceNotFound = "ServiceNotFound"
+	// ReasonShortenerNotReady means the ShortenerService of a ShortURL cannot
+	// serve links yet.
+	ReasonShortenerNotReady = "ShortenerNotReady"
+)
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=shortener
+// +kubebuilder:printcolumn:name="Image",type=string,JSONPath=".spec.image"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="URL",type=string,JSONPath=".status.url"
+
+// ShortenerService is the Schema for the shortenerservices API. Each one runs
+// an isolated shortener API that ShortURLs in its namespace can register
+// links with.
+type ShortenerService struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ShortenerServiceSpec   `json:"spec,omitempty"`
+	Status ShortenerServiceStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ShortenerServiceList contains a list of ShortenerService.
+type ShortenerServiceList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ShortenerService `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ShortenerService{}, &ShortenerServiceList{})
+}
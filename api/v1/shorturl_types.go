@@ -17,28 +17,197 @@ limitations under the License.
 package v1
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // ShortURLSpec defines the desired state of ShortURL.
+// +kubebuilder:validation:XValidation:rule="!has(self.notBefore) || !has(self.expireAt) || self.notBefore < self.expireAt",message="notBefore must be before expireAt"
 type ShortURLSpec struct {
 	TargetURL string       `json:"targetURL"`
 	ExpireAt  *metav1.Time `json:"expireAt,omitempty"`
+
+	// NotBefore is when the link starts to redirect, e.g. the launch of a
+	// campaign. Until then the shortener answers with its "not yet active"
+	// response. The link is active right away when unset.
+	// +optional
+	NotBefore *metav1.Time `json:"notBefore,omitempty"`
+
+	// MaxClicks limits how often the link redirects, e.g. for one-time
+	// invite links. Once it has been followed this often it behaves as
+	// expired. The link is not limited when unset.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxClicks *int32 `json:"maxClicks,omitempty"`
+
+	// Alias requests a readable short path such as "spring-sale" instead of
+	// a randomly generated one. It is only used when the link is first
+	// registered.
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_-]+$`
+	// +optional
+	Alias string `json:"alias,omitempty"`
+
+	// RedirectType is the HTTP status code the shortener answers with when
+	// the short URL is followed: 301 or 308 for permanent links, 302 or 307
+	// (which keeps the request method and body) for temporary ones. It is
+	// defaulted at admission time, and the shortener answers 302 if unset.
+	// +kubebuilder:validation:Enum=301;302;307;308
+	// +optional
+	RedirectType int32 `json:"redirectType,omitempty"`
+
+	// ServiceRef selects the ShortenerService in the same namespace that
+	// serves this link. The operator's default shortener is used when unset.
+	// It cannot be changed once the link is registered.
+	// +optional
+	ServiceRef *ShortenerServiceReference `json:"serviceRef,omitempty"`
+
+	// PollInterval overrides how often the operator refreshes the click count
+	// of this link from the shortener. The operator default is used when
+	// unset.
+	// +optional
+	PollInterval *metav1.Duration `json:"pollInterval,omitempty"`
+
+	// DeletionPolicy decides whether the ShortURL is deleted after it has
+	// expired. When unset, expired ShortURLs are retained unless their
+	// namespace sets the ttl-after-expiry annotation.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// TTLAfterExpiry is how long an expired ShortURL is kept before it is
+	// deleted, under the DeleteAfter policy or the ttl-after-expiry
+	// annotation of its namespace. It does not delete anything by itself.
+	// It defaults to the duration annotated on the namespace, or to deleting
+	// right away.
+	// +optional
+	TTLAfterExpiry *metav1.Duration `json:"ttlAfterExpiry,omitempty"`
 }
 
+// DeletionPolicy decides what happens to a ShortURL once it has expired.
+// +kubebuilder:validation:Enum=Retain;DeleteAfter
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyRetain keeps expired ShortURLs until they are deleted
+	// by hand.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicyDeleteAfter deletes ShortURLs, and their links in the
+	// shortener, once ttlAfterExpiry has passed since they expired.
+	DeletionPolicyDeleteAfter DeletionPolicy = "DeleteAfter"
+)
+
 // ShortURLStatus defines the observed state of ShortURL.
 type ShortURLStatus struct {
 	ShortPath  string `json:"shortPath,omitempty"`
 	ClickCount int    `json:"clickCount,omitempty"`
 	IsValid    string `json:"isValid,omitempty"`
+
+	// RemainingClicks is how often a link with spec.maxClicks can still be
+	// followed.
+	// +optional
+	RemainingClicks *int32 `json:"remainingClicks,omitempty"`
+
+	// URL is the full public short link. It is only reported when the
+	// ShortenerService sets a publicURL.
+	URL string `json:"url,omitempty"`
+
+	// ObservedGeneration is the most recent metadata.generation whose spec
+	// has been pushed to the shortener backend.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions describe the latest observations of the link's state.
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
+// DefaultTTLAnnotation can be set on a namespace to give new ShortURLs in it
+// an expireAt of creation time plus the annotated duration, e.g. "90d" or
+// "720h", unless they set one themselves.
+const DefaultTTLAnnotation = "urlshortener.shortener.io/default-ttl"
+
+// TTLAfterExpiryAnnotation can be set on a namespace to delete the ShortURLs
+// in it once the annotated duration, e.g. "7d", has passed since they
+// expired. It applies to ShortURLs without a deletionPolicy, and gives the
+// default ttlAfterExpiry of those with the DeleteAfter policy.
+const TTLAfterExpiryAnnotation = "urlshortener.shortener.io/ttl-after-expiry"
+
+// ParseTTL parses the duration of a TTL annotation, such as "720h" or
+// "90d". Days are accepted in addition to the units of time.ParseDuration,
+// as TTLs are usually given in days. The duration must be positive.
+func ParseTTL(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid number of days %q", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if ttl <= 0 {
+		return 0, fmt.Errorf("TTL %q must be positive", value)
+	}
+	return ttl, nil
+}
+
+// Condition types and reasons reported on ShortURL objects.
+const (
+	// ConditionReady tells whether the short path currently redirects to the
+	// target URL.
+	ConditionReady = "Ready"
+	// ConditionRegistered tells whether the link is registered with the
+	// shortener backend.
+	ConditionRegistered = "Registered"
+	// ConditionExpired tells whether the link has passed spec.expireAt or
+	// used up spec.maxClicks.
+	ConditionExpired = "Expired"
+	// ConditionBackendReachable tells whether the last call to the shortener
+	// backend succeeded.
+	ConditionBackendReachable = "BackendReachable"
+
+	// ReasonActive means the link is registered and not expired.
+	ReasonActive = "Active"
+	// ReasonExpired means the link has passed spec.expireAt.
+	ReasonExpired = "Expired"
+	// ReasonClickLimitReached means the link has been followed
+	// spec.maxClicks times.
+	ReasonClickLimitReached = "ClickLimitReached"
+	// ReasonNotYetActive means the link is registered but spec.notBefore
+	// has not come yet.
+	ReasonNotYetActive = "NotYetActive"
+	// ReasonBackendReachable means the shortener backend answered as expected.
+	ReasonBackendReachable = "BackendReachable"
+	// ReasonBackendError means the shortener backend could not be reached or
+	// returned an unexpected response.
+	ReasonBackendError = "BackendError"
+	// ReasonRegistered means the backend accepted the link.
+	ReasonRegistered = "Registered"
+	// ReasonAliasConflict means the requested alias is already taken by
+	// another link.
+	ReasonAliasConflict = "AliasConflict"
+	// ReasonInvalidAlias means the backend refused the requested alias,
+	// e.g. because it is a reserved word.
+	ReasonInvalidAlias = "InvalidAlias"
+	// ReasonRejected means the backend refused the link for another reason
+	// than its alias, e.g. a target URL or redirect type it does not accept.
+	ReasonRejected = "Rejected"
+)
+
 // +kubebuilder:resource:shortName=sl
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="ShortPath",type=string,JSONPath=".status.shortPath"
 // +kubebuilder:printcolumn:name="ClickCount",type=integer,JSONPath=".status.clickCount"
 // +kubebuilder:printcolumn:name="IsValid",type=string,JSONPath=".status.isValid"
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
 
 // ShortURL is the Schema for the shorturls API.
 type ShortURL struct {
@@ -19,18 +19,24 @@ package main
 import (
 	"crypto/tls"
 	"flag"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
@@ -39,6 +45,8 @@ import (
 
 	urlshortenerv1 "urlshortener-operator/api/v1"
 	"urlshortener-operator/internal/controller"
+	"urlshortener-operator/internal/shortener"
+	webhookv1 "urlshortener-operator/internal/webhook/v1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -63,6 +71,16 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var shortenerStorageSize, shortenerStorageClass string
+	var allowedSchemes, allowedDomains, deniedDomains string
+	var defaultRedirectType int
+	var defaultURLScheme string
+	var defaultTTL time.Duration
+	var shortenerTimeout time.Duration
+	var shortenerRetries int
+	var pollInterval time.Duration
+	var clickEvents bool
+	var shortenerProtocol string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -81,6 +99,38 @@ func main() {
 	flag.StringVar(&metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&shortenerStorageSize, "shortener-storage-size", "",
+		"If set, the default shortener API keeps its links on a PersistentVolumeClaim of this size (e.g. 1Gi) "+
+			"instead of in memory. Only used when the operator creates the default ShortenerService.")
+	flag.StringVar(&shortenerStorageClass, "shortener-storage-class", "",
+		"The storage class of the default shortener API PersistentVolumeClaim. Uses the cluster default if empty.")
+	flag.StringVar(&allowedSchemes, "allowed-url-schemes", "http,https",
+		"Comma separated list of schemes a ShortURL target may use.")
+	flag.StringVar(&allowedDomains, "allowed-target-domains", "",
+		"Comma separated list of domains ShortURL targets are restricted to, including their subdomains. "+
+			"Any domain is allowed if empty.")
+	flag.StringVar(&deniedDomains, "denied-target-domains", "",
+		"Comma separated list of domains, including their subdomains, ShortURL targets may not point to.")
+	flag.IntVar(&defaultRedirectType, "default-redirect-type", http.StatusFound,
+		"The redirect status code given to ShortURLs that do not set spec.redirectType.")
+	flag.StringVar(&defaultURLScheme, "default-url-scheme", "https",
+		"The scheme added to ShortURL targets that are missing one.")
+	flag.DurationVar(&defaultTTL, "default-ttl", 0,
+		"The expiry given to new ShortURLs without spec.expireAt in namespaces that do not set the "+
+			urlshortenerv1.DefaultTTLAnnotation+" annotation. Links never expire by default.")
+	flag.DurationVar(&shortenerTimeout, "shortener-timeout", 10*time.Second,
+		"The timeout of each request to a shortener API.")
+	flag.IntVar(&shortenerRetries, "shortener-retries", 3,
+		"How often failed requests to a shortener API are retried. Set to -1 to disable retries.")
+	flag.StringVar(&shortenerProtocol, "shortener-protocol", "http",
+		"The API the operator uses to talk to the shorteners: http or grpc. "+
+			"Shorteners without a gRPC address are always reached over HTTP.")
+	flag.DurationVar(&pollInterval, "poll-interval", 0,
+		"How often click counts are refreshed from the shortener API, unless a ShortURL sets spec.pollInterval. "+
+			"Defaults to 1m, or to 10m when click events are enabled.")
+	flag.BoolVar(&clickEvents, "click-events", true,
+		"If set, click counts are pushed by the shortener APIs through their event streams, "+
+			"and polling only catches up on missed events.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -185,6 +235,10 @@ func main() {
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
 		LeaderElectionID:       "f849119f.shortener.io",
+		// Only the API key Secrets are cached, not every Secret of the cluster.
+		Cache: cache.Options{ByObject: map[client.Object]cache.ByObject{
+			&corev1.Secret{}: {Label: controller.APIKeySecretSelector},
+		}},
 		// LeaderElectionReleaseOnCancel defines if the leader should step down voluntarily
 		// when the Manager ends. This requires the binary to immediately end when the
 		// Manager is stopped, otherwise, this setting is unsafe. Setting this significantly
@@ -202,13 +256,82 @@ func main() {
 		os.Exit(1)
 	}
 
+	shortenerOpts := shortener.Options{
+		Timeout:    shortenerTimeout,
+		MaxRetries: shortenerRetries,
+	}
+	var shorteners shortener.Factory
+	var grpcClients *shortener.GRPCFactory
+	switch shortenerProtocol {
+	case "http":
+		shorteners = shortener.NewFactory(shortenerOpts)
+	case "grpc":
+		grpcClients = shortener.NewGRPCFactory(shortenerOpts)
+		shorteners = grpcClients.Client
+	default:
+		setupLog.Error(nil, "invalid --shortener-protocol, must be http or grpc", "protocol", shortenerProtocol)
+		os.Exit(1)
+	}
+	if pollInterval == 0 {
+		pollInterval = time.Minute
+		if clickEvents {
+			pollInterval = 10 * time.Minute
+		}
+	}
 	if err = (&controller.ShortURLReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorderFor("shorturl-controller"),
+		DefaultShortener: controller.DefaultShortenerKey,
+		Shorteners:       shorteners,
+		PollInterval:     pollInterval,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ShortURL")
 		os.Exit(1)
 	}
+	if err = (&controller.ShortenerServiceReconciler{
+		Client:      mgr.GetClient(),
+		Scheme:      mgr.GetScheme(),
+		Recorder:    mgr.GetEventRecorderFor("shortenerservice-controller"),
+		GRPCClients: grpcClients,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ShortenerService")
+		os.Exit(1)
+	}
+	if err = mgr.Add(&controller.DefaultShortener{
+		Client:           mgr.GetClient(),
+		Key:              controller.DefaultShortenerKey,
+		StorageSize:      shortenerStorageSize,
+		StorageClassName: shortenerStorageClass,
+	}); err != nil {
+		setupLog.Error(err, "unable to set up default shortener")
+		os.Exit(1)
+	}
+	if clickEvents {
+		if err = (&controller.ClickFeed{
+			Client:           mgr.GetClient(),
+			DefaultShortener: controller.DefaultShortenerKey,
+			Shorteners:       shorteners,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to set up click feed")
+			os.Exit(1)
+		}
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhookv1.SetupShortURLWebhookWithManager(mgr, &webhookv1.ShortURLCustomDefaulter{
+			RedirectType: int32(defaultRedirectType),
+			URLScheme:    defaultURLScheme,
+			TTL:          defaultTTL,
+		}, &webhookv1.ShortURLCustomValidator{
+			AllowedSchemes: splitList(allowedSchemes),
+			AllowedDomains: splitList(allowedDomains),
+			DeniedDomains:  splitList(deniedDomains),
+		}); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ShortURL")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	if metricsCertWatcher != nil {
@@ -242,3 +365,14 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// splitList parses a comma separated flag value, dropping empty entries.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
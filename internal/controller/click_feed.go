@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+	"urlshortener-operator/internal/shortener"
+)
+
+const (
+	// defaultFeedResyncInterval is how often the ClickFeed looks for
+	// shorteners to subscribe to or unsubscribe from.
+	defaultFeedResyncInterval = 10 * time.Second
+	// feedReconnectDelay is the wait before a broken event stream is opened
+	// again.
+	feedReconnectDelay = 5 * time.Second
+)
+
+// shortPathIndex indexes ShortURLs by the ShortenerService serving them and
+// their short path, so click events can be mapped back to them.
+const shortPathIndex = ".status.shortPath"
+
+// ClickFeed subscribes to the click events of every ready ShortenerService
+// and copies the click counts they report into the ShortURLs. It makes click
+// counts current within seconds; the polling of the ShortURL controller stays
+// in place for links whose events were missed, e.g. while a stream was down.
+type ClickFeed struct {
+	client.Client
+
+	// DefaultShortener is the ShortenerService used by ShortURLs without a
+	// serviceRef.
+	DefaultShortener types.NamespacedName
+	// Shorteners creates the clients for the shortener APIs. A client with
+	// default options is used when nil.
+	Shorteners shortener.Factory
+	// ResyncInterval is how often the list of ShortenerServices is checked
+	// for shorteners that became ready or went away. Defaults to 10 seconds.
+	ResyncInterval time.Duration
+}
+
+// feedStream is a running subscription to one shortener.
+type feedStream struct {
+	endpoint shortener.Endpoint
+	cancel   context.CancelFunc
+}
+
+// SetupWithManager indexes ShortURLs by short path and adds the feed to the
+// manager, which runs it on the leader only.
+func (f *ClickFeed) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &urlshortenerv1.ShortURL{}, shortPathIndex,
+		func(obj client.Object) []string {
+			shortURL := obj.(*urlshortenerv1.ShortURL)
+			if shortURL.Status.ShortPath == "" {
+				return nil
+			}
+			return []string{shortPathKey(shortenerKeyFor(shortURL, f.DefaultShortener), shortURL.Status.ShortPath)}
+		})
+	if err != nil {
+		return err
+	}
+	return mgr.Add(f)
+}
+
+// Start implements manager.Runnable.
+func (f *ClickFeed) Start(ctx context.Context) error {
+	interval := f.ResyncInterval
+	if interval == 0 {
+		interval = defaultFeedResyncInterval
+	}
+
+	streams := map[types.NamespacedName]feedStream{}
+	defer func() {
+		for _, stream := range streams {
+			stream.cancel()
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		f.resync(ctx, streams)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// resync starts a stream for each ready ShortenerService and stops the
+// streams of those that are gone, not ready, moved to another address or
+// given another API key.
+func (f *ClickFeed) resync(ctx context.Context, streams map[types.NamespacedName]feedStream) {
+	var svcs urlshortenerv1.ShortenerServiceList
+	if err := f.List(ctx, &svcs); err != nil {
+		log.Println("Unable to list ShortenerServices for click events:", err)
+		return
+	}
+
+	wanted := map[types.NamespacedName]shortener.Endpoint{}
+	for _, svc := range svcs.Items {
+		if svc.DeletionTimestamp.IsZero() && svc.Status.URL != "" &&
+			meta.IsStatusConditionTrue(svc.Status.Conditions, urlshortenerv1.ConditionReady) {
+			endpoint, err := shortenerEndpoint(ctx, f.Client, &svc)
+			if err != nil {
+				log.Println("Unable to subscribe to click events:", err)
+				continue
+			}
+			wanted[client.ObjectKeyFromObject(&svc)] = endpoint
+		}
+	}
+
+	for key, stream := range streams {
+		if wanted[key] != stream.endpoint {
+			stream.cancel()
+			delete(streams, key)
+		}
+	}
+	for key, endpoint := range wanted {
+		if _, ok := streams[key]; ok {
+			continue
+		}
+		streamCtx, cancel := context.WithCancel(ctx)
+		streams[key] = feedStream{endpoint: endpoint, cancel: cancel}
+		go f.watch(streamCtx, key, endpoint)
+	}
+}
+
+// watch follows the click events of one shortener until ctx is done,
+// reconnecting whenever the stream breaks.
+func (f *ClickFeed) watch(ctx context.Context, key types.NamespacedName, endpoint shortener.Endpoint) {
+	api := f.shortenerClient(endpoint)
+	for {
+		err := api.WatchClicks(ctx, func(batch []shortener.ClickEvent) {
+			f.apply(ctx, key, batch)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		log.Println("Click events of ShortenerService", key, "interrupted:", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(feedReconnectDelay):
+		}
+	}
+}
+
+// apply copies the click counts of a batch into the matching ShortURLs.
+// Events of links without a ShortURL, e.g. ones created through the API
+// directly, are ignored.
+func (f *ClickFeed) apply(ctx context.Context, key types.NamespacedName, batch []shortener.ClickEvent) {
+	for _, event := range batch {
+		var shortURLs urlshortenerv1.ShortURLList
+		err := f.List(ctx, &shortURLs, client.MatchingFields{shortPathIndex: shortPathKey(key, event.ShortURL)})
+		if err != nil {
+			log.Println("Unable to list ShortURLs for click event of", event.ShortURL, err)
+			continue
+		}
+
+		for i := range shortURLs.Items {
+			shortURL := &shortURLs.Items[i]
+			if shortURL.Status.ClickCount == event.ClickCount {
+				continue
+			}
+			patch := client.MergeFrom(shortURL.DeepCopy())
+			shortURL.Status.ClickCount = event.ClickCount
+			if limit := shortURL.Spec.MaxClicks; limit != nil {
+				remaining := max(*limit-int32(event.ClickCount), 0)
+				shortURL.Status.RemainingClicks = &remaining
+			}
+			if err := f.Status().Patch(ctx, shortURL, patch); err != nil {
+				log.Println("Unable to update click count of ShortURL", client.ObjectKeyFromObject(shortURL), err)
+			}
+		}
+	}
+}
+
+func (f *ClickFeed) shortenerClient(endpoint shortener.Endpoint) shortener.Client {
+	if f.Shorteners == nil {
+		return shortener.New(endpoint.URL, shortener.Options{APIKey: endpoint.APIKey})
+	}
+	return f.Shorteners(endpoint)
+}
+
+// shortPathKey is the shortPathIndex value of a short path on a shortener.
+func shortPathKey(key types.NamespacedName, shortPath string) string {
+	return key.String() + "/" + shortPath
+}
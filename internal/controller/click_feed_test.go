@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+	"urlshortener-operator/internal/shortener"
+)
+
+var _ = Describe("ClickFeed", func() {
+	ctx := context.Background()
+	defaultShortener := types.NamespacedName{Name: "default-shortener", Namespace: "system"}
+
+	newShortURL := func(name string, serviceRef *urlshortenerv1.ShortenerServiceReference) *urlshortenerv1.ShortURL {
+		return &urlshortenerv1.ShortURL{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       urlshortenerv1.ShortURLSpec{TargetURL: "https://example.com", ServiceRef: serviceRef},
+			Status:     urlshortenerv1.ShortURLStatus{ShortPath: "abc", ClickCount: 1},
+		}
+	}
+
+	It("should copy click counts into the ShortURLs of the shortener", func() {
+		scheme := runtime.NewScheme()
+		Expect(urlshortenerv1.AddToScheme(scheme)).To(Succeed())
+
+		feed := &ClickFeed{DefaultShortener: defaultShortener}
+		served := newShortURL("served", nil)
+		limit := int32(3)
+		served.Spec.MaxClicks = &limit
+		other := newShortURL("other", &urlshortenerv1.ShortenerServiceReference{Name: "other"})
+		feed.Client = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(served, other).
+			WithStatusSubresource(served, other).
+			WithIndex(&urlshortenerv1.ShortURL{}, shortPathIndex, func(obj client.Object) []string {
+				shortURL := obj.(*urlshortenerv1.ShortURL)
+				return []string{shortPathKey(shortenerKeyFor(shortURL, defaultShortener), shortURL.Status.ShortPath)}
+			}).
+			Build()
+
+		feed.apply(ctx, defaultShortener, []shortener.ClickEvent{
+			{ShortURL: "abc", Clicks: 4, ClickCount: 5},
+			{ShortURL: "unknown", Clicks: 1, ClickCount: 1},
+		})
+
+		Expect(feed.Get(ctx, client.ObjectKeyFromObject(served), served)).To(Succeed())
+		Expect(served.Status.ClickCount).To(Equal(5))
+		Expect(served.Status.RemainingClicks).To(HaveValue(BeZero()))
+		By("leaving links with the same path on other shorteners alone")
+		Expect(feed.Get(ctx, client.ObjectKeyFromObject(other), other)).To(Succeed())
+		Expect(other.Status.ClickCount).To(Equal(1))
+	})
+})
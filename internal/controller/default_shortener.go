@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+	"log"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+// DefaultShortenerKey is where the operator runs the shortener used by
+// ShortURLs without a serviceRef. The name matches the objects created by
+// earlier versions of the operator, so they are adopted on upgrade.
+var DefaultShortenerKey = types.NamespacedName{Name: "urlshortener-api", Namespace: "urlshortener-operator-system"}
+
+// DefaultShortener creates the ShortenerService used by ShortURLs without a
+// serviceRef when the manager starts. An existing one is left alone, so it
+// can be customized like any other ShortenerService.
+type DefaultShortener struct {
+	client.Client
+	Key types.NamespacedName
+
+	// StorageSize enables persistent storage for the default shortener API
+	// when set, requesting a PersistentVolumeClaim of this size (e.g. "1Gi").
+	StorageSize string
+	// StorageClassName is the storage class of the PersistentVolumeClaim.
+	// The cluster default is used when empty.
+	StorageClassName string
+}
+
+// Start implements manager.Runnable.
+func (d *DefaultShortener) Start(ctx context.Context) error {
+	svc := &urlshortenerv1.ShortenerService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      d.Key.Name,
+			Namespace: d.Key.Namespace,
+		},
+	}
+	if d.StorageSize != "" {
+		size, err := resource.ParseQuantity(d.StorageSize)
+		if err != nil {
+			return err
+		}
+		svc.Spec.Storage = &urlshortenerv1.ShortenerStorage{
+			Size:             size,
+			StorageClassName: d.StorageClassName,
+		}
+	}
+
+	err := d.Create(ctx, svc)
+	if apierrors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	log.Println("Created default ShortenerService", d.Key)
+	return nil
+}
@@ -0,0 +1,234 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+	"urlshortener-operator/internal/shortener"
+)
+
+// ShortenerServiceReconciler reconciles a ShortenerService object
+type ShortenerServiceReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// GRPCClients, when set, creates the gRPC clients of the operator. Their
+	// connections to shorteners that were deleted or moved to another address
+	// are closed.
+	GRPCClients *shortener.GRPCFactory
+}
+
+// +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shortenerservices,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shortenerservices/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shortenerservices/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+// Reconcile applies the PersistentVolumeClaim, API key Secret, Deployment and
+// Service that run the shortener API described by a ShortenerService, and reports whether it
+// is ready to serve links.
+func (r *ShortenerServiceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var svc urlshortenerv1.ShortenerService
+	if err := r.Get(ctx, req.NamespacedName, &svc); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, r.closeUnusedConnections(ctx, nil)
+		}
+		return ctrl.Result{}, err
+	}
+	if !svc.DeletionTimestamp.IsZero() {
+		// The owned objects are garbage collected with the ShortenerService.
+		return ctrl.Result{}, r.closeUnusedConnections(ctx, nil)
+	}
+
+	if err := ensureShortenerInfrastructure(ctx, r.Client, &svc); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKey{Name: svc.Name, Namespace: svc.Namespace}, deployment); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	wasReady := meta.IsStatusConditionTrue(svc.Status.Conditions, urlshortenerv1.ConditionReady)
+	oldGRPCAddress := svc.Status.GRPCAddress
+	svc.Status.URL = shortenerServiceURL(&svc)
+	svc.Status.GRPCAddress = shortenerServiceGRPCAddress(&svc)
+	svc.Status.APIKeySecret = shortenerAPIKeySecretName(&svc)
+	svc.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+	ready := deployment.Status.ReadyReplicas > 0
+	if ready {
+		meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+			Type:               urlshortenerv1.ConditionReady,
+			Status:             metav1.ConditionTrue,
+			Reason:             urlshortenerv1.ReasonDeploymentAvailable,
+			Message:            fmt.Sprintf("%d shortener API pods are ready", deployment.Status.ReadyReplicas),
+			ObservedGeneration: svc.Generation,
+		})
+	} else {
+		meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+			Type:               urlshortenerv1.ConditionReady,
+			Status:             metav1.ConditionFalse,
+			Reason:             urlshortenerv1.ReasonDeploymentUnavailable,
+			Message:            "No shortener API pod is ready yet",
+			ObservedGeneration: svc.Generation,
+		})
+	}
+	if err := r.Status().Update(ctx, &svc); err != nil {
+		return ctrl.Result{}, err
+	}
+	if ready && !wasReady {
+		r.Recorder.Eventf(&svc, corev1.EventTypeNormal, "Ready", "Shortener API is serving at %s", svc.Status.URL)
+	}
+	if oldGRPCAddress != "" && oldGRPCAddress != svc.Status.GRPCAddress {
+		if err := r.closeUnusedConnections(ctx, &svc); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Changes to the owned objects, including the Deployment becoming ready,
+	// trigger the next reconciliation.
+	return ctrl.Result{}, nil
+}
+
+// closeUnusedConnections closes the gRPC connections of the operator to
+// addresses no ShortenerService serves anymore. current is the
+// ShortenerService being reconciled, whose new address the cache may not
+// know yet; it is nil when that one is gone.
+func (r *ShortenerServiceReconciler) closeUnusedConnections(
+	ctx context.Context, current *urlshortenerv1.ShortenerService,
+) error {
+	if r.GRPCClients == nil {
+		return nil
+	}
+	var svcs urlshortenerv1.ShortenerServiceList
+	if err := r.List(ctx, &svcs); err != nil {
+		return err
+	}
+	var inUse []string
+	if current != nil {
+		inUse = append(inUse, current.Status.GRPCAddress)
+	}
+	for _, svc := range svcs.Items {
+		if !svc.DeletionTimestamp.IsZero() ||
+			(current != nil && client.ObjectKeyFromObject(&svc) == client.ObjectKeyFromObject(current)) {
+			continue
+		}
+		inUse = append(inUse, svc.Status.GRPCAddress)
+	}
+	r.GRPCClients.CloseUnused(inUse)
+	return nil
+}
+
+// ensureShortenerInfrastructure applies the objects that run the shortener
+// API of svc.
+func ensureShortenerInfrastructure(ctx context.Context, c client.Client, svc *urlshortenerv1.ShortenerService) error {
+	if err := ensureShortenerStorage(ctx, c, svc); err != nil {
+		return err
+	}
+	if err := ensureShortenerAPIKey(ctx, c, svc); err != nil {
+		return err
+	}
+	if err := ensureShortenerDeployment(ctx, c, svc); err != nil {
+		return err
+	}
+	return ensureShortenerService(ctx, c, svc)
+}
+
+// shortenerLabels selects the pods of the shortener API of svc.
+func shortenerLabels(svc *urlshortenerv1.ShortenerService) map[string]string {
+	return map[string]string{"app": svc.Name}
+}
+
+// shortenerClaimName is the name of the PersistentVolumeClaim holding the
+// database of svc.
+func shortenerClaimName(svc *urlshortenerv1.ShortenerService) string {
+	return svc.Name + "-data"
+}
+
+// shortenerServicePort is the port the Service of svc exposes.
+func shortenerServicePort(svc *urlshortenerv1.ShortenerService) int32 {
+	if svc.Spec.Port == 0 {
+		return shortenerContainerPort
+	}
+	return svc.Spec.Port
+}
+
+// shortenerServiceURL is the in-cluster address of the shortener API of svc.
+func shortenerServiceURL(svc *urlshortenerv1.ShortenerService) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d", svc.Name, svc.Namespace, shortenerServicePort(svc))
+}
+
+// shortenerGRPCServicePort is the port the Service of svc exposes the gRPC API
+// on.
+func shortenerGRPCServicePort(svc *urlshortenerv1.ShortenerService) int32 {
+	if svc.Spec.GRPCPort == 0 {
+		return shortenerGRPCContainerPort
+	}
+	return svc.Spec.GRPCPort
+}
+
+// shortenerServiceGRPCAddress is the in-cluster host:port of the gRPC API of
+// svc.
+func shortenerServiceGRPCAddress(svc *urlshortenerv1.ShortenerService) string {
+	return fmt.Sprintf("%s.%s.svc.cluster.local:%d", svc.Name, svc.Namespace, shortenerGRPCServicePort(svc))
+}
+
+// fieldOwner is the server-side apply field manager of the operator.
+const fieldOwner = client.FieldOwner("urlshortener-operator")
+
+// applyShortenerObject server-side applies obj as part of the shortener API
+// of svc. Fields set by the operator are forced back to their desired value
+// whoever changed them; fields it does not set are left alone. The objects
+// are controlled by svc, so they are garbage collected with it and changes to
+// them trigger its reconciliation.
+func applyShortenerObject(
+	ctx context.Context, c client.Client, svc *urlshortenerv1.ShortenerService, obj client.Object,
+) error {
+	if err := controllerutil.SetControllerReference(svc, obj, c.Scheme()); err != nil {
+		return err
+	}
+	return c.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ShortenerServiceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&urlshortenerv1.ShortenerService{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.Secret{}).
+		Named("shortenerservice").
+		Complete(r)
+}
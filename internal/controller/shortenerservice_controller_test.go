@@ -0,0 +1,288 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+	"urlshortener-operator/internal/shortener"
+)
+
+var _ = Describe("ShortenerService Controller", func() {
+	Context("When reconciling a resource", func() {
+		const resourceName = "test-shortener"
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			By("creating the custom resource for the Kind ShortenerService")
+			err := k8sClient.Get(ctx, typeNamespacedName, &urlshortenerv1.ShortenerService{})
+			if err != nil && apierrors.IsNotFound(err) {
+				resource := &urlshortenerv1.ShortenerService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      resourceName,
+						Namespace: "default",
+					},
+					Spec: urlshortenerv1.ShortenerServiceSpec{
+						Image: "docker.io/sadegh81/url-shortener:v3",
+						Port:  9090,
+						Storage: &urlshortenerv1.ShortenerStorage{
+							Size: resource.MustParse("1Gi"),
+						},
+					},
+				}
+				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			}
+		})
+
+		AfterEach(func() {
+			resource := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+
+			By("cleaning up the ShortenerService resource")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+		})
+
+		It("should create the shortener API and report its address", func() {
+			controllerReconciler := &ShortenerServiceReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			svc := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			Expect(svc.Status.URL).To(Equal("http://test-shortener.default.svc.cluster.local:9090"))
+			Expect(svc.Status.GRPCAddress).To(Equal("test-shortener.default.svc.cluster.local:50051"))
+			Expect(svc.Status.APIKeySecret).To(Equal("test-shortener-api-key"))
+			// No pods run in the test environment.
+			Expect(meta.IsStatusConditionFalse(svc.Status.Conditions, urlshortenerv1.ConditionReady)).To(BeTrue())
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(metav1.IsControlledBy(deployment, svc)).To(BeTrue())
+			Expect(deployment.Spec.Template.Spec.Volumes).To(HaveLen(2))
+			Expect(deployment.Spec.Template.Spec.Volumes[0].Secret.SecretName).To(Equal("test-shortener-api-key"))
+			Expect(deployment.Spec.Template.Spec.Volumes[1].PersistentVolumeClaim.ClaimName).
+				To(Equal("test-shortener-data"))
+
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-shortener-api-key", Namespace: "default"}, secret)).
+				To(Succeed())
+			Expect(metav1.IsControlledBy(secret, svc)).To(BeTrue())
+			Expect(secret.Data[shortenerAPIKeySecretKey]).NotTo(BeEmpty())
+			Expect(APIKeySecretSelector.Matches(labels.Set(secret.Labels))).To(BeTrue(),
+				"the manager only caches the Secrets matching APIKeySecretSelector")
+
+			endpoint, err := shortenerEndpoint(ctx, k8sClient, svc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(endpoint.APIKey).To(Equal(string(secret.Data[shortenerAPIKeySecretKey])))
+
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			Expect(metav1.IsControlledBy(service, svc)).To(BeTrue())
+			Expect(service.Spec.Ports).To(HaveLen(2))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(9090)))
+			Expect(service.Spec.Ports[1].Name).To(Equal("grpc"))
+			Expect(service.Spec.Ports[1].Port).To(Equal(int32(50051)))
+
+			pvc := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "test-shortener-data", Namespace: "default"}, pvc)).
+				To(Succeed())
+			Expect(metav1.IsControlledBy(pvc, svc)).To(BeTrue())
+		})
+
+		It("should refuse more than one replica", func() {
+			svc := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			svc.Spec.Storage = nil
+			svc.Spec.Replicas = ptr.To(int32(2))
+			err := k8sClient.Update(ctx, svc)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue(), "got %v", err)
+		})
+
+		It("should keep the API key, including one set by the user", func() {
+			controllerReconciler := &ShortenerServiceReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			secretName := types.NamespacedName{Name: "test-shortener-api-key", Namespace: "default"}
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			generated := secret.Data[shortenerAPIKeySecretKey]
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, secretName, secret)).To(Succeed())
+			Expect(secret.Data[shortenerAPIKeySecretKey]).To(Equal(generated))
+
+			By("rotating the key")
+			secret.Data[shortenerAPIKeySecretKey] = []byte("rotated\n" + string(generated))
+			Expect(k8sClient.Update(ctx, secret)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			svc := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			endpoint, err := shortenerEndpoint(ctx, k8sClient, svc)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(endpoint.APIKey).To(Equal("rotated"))
+		})
+
+		It("should revert drift in the managed Deployment and Service", func() {
+			controllerReconciler := &ShortenerServiceReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("scaling the Deployment down and changing its image")
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			deployment.Spec.Replicas = ptr.To(int32(0))
+			deployment.Spec.Template.Spec.Containers[0].Image = "example.com/other:latest"
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			By("changing the Service port")
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			service.Spec.Ports[0].Port = 80
+			Expect(k8sClient.Update(ctx, service)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+			Expect(*deployment.Spec.Replicas).To(Equal(int32(1)))
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("docker.io/sadegh81/url-shortener:v3"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, service)).To(Succeed())
+			Expect(service.Spec.Ports).To(HaveLen(2))
+			Expect(service.Spec.Ports[0].Name).To(Equal("http"))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(9090)))
+		})
+
+		It("should switch the rollout strategy with the storage", func() {
+			controllerReconciler := &ShortenerServiceReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(100),
+			}
+			reconcileShortener := func() *appsv1.Deployment {
+				_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+				Expect(err).NotTo(HaveOccurred())
+				deployment := &appsv1.Deployment{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, deployment)).To(Succeed())
+				return deployment
+			}
+			setStorage := func(storage *urlshortenerv1.ShortenerStorage) {
+				svc := &urlshortenerv1.ShortenerService{}
+				Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+				svc.Spec.Storage = storage
+				Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+			}
+
+			By("recreating pods that share a volume")
+			deployment := reconcileShortener()
+			Expect(deployment.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+			Expect(deployment.Spec.Strategy.RollingUpdate).To(BeNil())
+
+			By("rolling pods without storage")
+			setStorage(nil)
+			deployment = reconcileShortener()
+			Expect(deployment.Spec.Strategy.Type).To(Equal(appsv1.RollingUpdateDeploymentStrategyType))
+			Expect(deployment.Spec.Strategy.RollingUpdate).NotTo(BeNil())
+
+			By("recreating pods again once storage is added back")
+			setStorage(&urlshortenerv1.ShortenerStorage{Size: resource.MustParse("1Gi")})
+			deployment = reconcileShortener()
+			Expect(deployment.Spec.Strategy.Type).To(Equal(appsv1.RecreateDeploymentStrategyType))
+			Expect(deployment.Spec.Strategy.RollingUpdate).To(BeNil())
+		})
+
+		It("should close the gRPC connections to addresses it no longer serves", func() {
+			grpcClients := shortener.NewGRPCFactory(shortener.Options{})
+			DeferCleanup(grpcClients.CloseUnused, []string(nil))
+			controllerReconciler := &ShortenerServiceReconciler{
+				Client:      k8sClient,
+				Scheme:      k8sClient.Scheme(),
+				Recorder:    record.NewFakeRecorder(100),
+				GRPCClients: grpcClients,
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			oldEndpoint := shortener.Endpoint{GRPCAddress: "test-shortener.default.svc.cluster.local:50051"}
+			oldClient := grpcClients.Client(oldEndpoint)
+			Expect(grpcClients.Client(oldEndpoint)).To(BeIdenticalTo(oldClient))
+
+			By("moving the gRPC API to another port")
+			svc := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, svc)).To(Succeed())
+			svc.Spec.GRPCPort = 50052
+			Expect(k8sClient.Update(ctx, svc)).To(Succeed())
+			newEndpoint := shortener.Endpoint{GRPCAddress: "test-shortener.default.svc.cluster.local:50052"}
+			newClient := grpcClients.Client(newEndpoint)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(grpcClients.Client(oldEndpoint)).NotTo(BeIdenticalTo(oldClient),
+				"the connection to the old address should have been closed")
+			Expect(grpcClients.Client(newEndpoint)).To(BeIdenticalTo(newClient))
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+// setCondition records a condition observed for the current generation of
+// the ShortURL. The transition time only moves when the status changes.
+func setCondition(shortURL *urlshortenerv1.ShortURL, conditionType string,
+	status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&shortURL.Status.Conditions, metav1.Condition{
+		Type:               conditionType,
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: shortURL.Generation,
+	})
+}
+
+// setNotReady marks the ShortURL as not ready for the given reason.
+func setNotReady(shortURL *urlshortenerv1.ShortURL, reason, message string) {
+	setCondition(shortURL, urlshortenerv1.ConditionReady, metav1.ConditionFalse, reason, message)
+}
+
+// setBackendStatus records the outcome of a successful round of backend
+// calls: the link is registered, the backend is reachable, and readiness
+// follows from whether the link has expired or is not active yet at now.
+func setBackendStatus(shortURL *urlshortenerv1.ShortURL, now time.Time) {
+	path := "/" + shortURL.Status.ShortPath
+
+	setCondition(shortURL, urlshortenerv1.ConditionRegistered, metav1.ConditionTrue,
+		urlshortenerv1.ReasonRegistered, "Link registered as "+path)
+	setCondition(shortURL, urlshortenerv1.ConditionBackendReachable, metav1.ConditionTrue,
+		urlshortenerv1.ReasonBackendReachable, "Shortener backend answered")
+
+	if shortURL.Status.IsValid == "false" {
+		reason, message := urlshortenerv1.ReasonExpired, "Link expired and no longer redirects"
+		if remaining := shortURL.Status.RemainingClicks; remaining != nil && *remaining == 0 {
+			reason, message = urlshortenerv1.ReasonClickLimitReached, "Link was followed its maximum number of times"
+		}
+		setCondition(shortURL, urlshortenerv1.ConditionExpired, metav1.ConditionTrue, reason, message)
+		setNotReady(shortURL, reason, message)
+		return
+	}
+
+	setCondition(shortURL, urlshortenerv1.ConditionExpired, metav1.ConditionFalse,
+		urlshortenerv1.ReasonActive, "Link has not expired")
+	if notBefore := shortURL.Spec.NotBefore; notBefore != nil && now.Before(notBefore.Time) {
+		setNotReady(shortURL, urlshortenerv1.ReasonNotYetActive,
+			path+" redirects from "+notBefore.UTC().Format(time.RFC3339))
+		return
+	}
+	setCondition(shortURL, urlshortenerv1.ConditionReady, metav1.ConditionTrue,
+		urlshortenerv1.ReasonActive, path+" redirects to "+shortURL.Spec.TargetURL)
+}
@@ -18,29 +18,62 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	urlshortenerv1 "urlshortener-operator/api/v1"
+	"urlshortener-operator/internal/shortener"
 )
 
 // ShortURLReconciler reconciles a ShortURL object
 type ShortURLReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// DefaultShortener is the ShortenerService used by ShortURLs without a
+	// serviceRef.
+	DefaultShortener types.NamespacedName
+	// Shorteners creates the clients for the shortener APIs. A client with
+	// default options is used when nil.
+	Shorteners shortener.Factory
+	// PollInterval is how often click counts are refreshed from the
+	// shortener, unless a ShortURL sets spec.pollInterval. Defaults to a
+	// minute.
+	PollInterval time.Duration
 }
 
-var ShortenerServiceURL = "http://urlshortener-api.urlshortener-operator-system.svc.cluster.local:8080"
+// shortURLFinalizer keeps a ShortURL around until its link has been removed
+// from the shortener backend.
+const shortURLFinalizer = "urlshortener.shortener.io/finalizer"
 
 // +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shorturls,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shorturls/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shorturls/finalizers,verbs=update
+// +kubebuilder:rbac:groups=urlshortener.shortener.io,resources=shortenerservices,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -53,58 +86,434 @@ var ShortenerServiceURL = "http://urlshortener-api.urlshortener-operator-system.
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.20.2/pkg/reconcile
 func (r *ShortURLReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log.Println("Started reconcilation loop")
-	err := r.ensureShortenerDeployment(ctx)
+	var shortURL urlshortenerv1.ShortURL
+	if err := r.Get(ctx, req.NamespacedName, &shortURL); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// The ShortenerService controller keeps the backend running and reports
+	// its readiness, so only that cached signal is checked here.
+	svc := &urlshortenerv1.ShortenerService{}
+	err := r.Get(ctx, r.shortenerKey(&shortURL), svc)
+	if !shortURL.DeletionTimestamp.IsZero() {
+		if apierrors.IsNotFound(err) {
+			// The links of a deleted ShortenerService went away with it.
+			return ctrl.Result{}, r.finalizeShortURL(ctx, &shortURL, nil)
+		}
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		var api shortener.Client
+		if svc.Status.URL != "" {
+			if api, err = r.shortenerClient(ctx, svc); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, r.finalizeShortURL(ctx, &shortURL, api)
+	}
+	if apierrors.IsNotFound(err) {
+		return r.shortenerNotFound(ctx, &shortURL)
+	}
 	if err != nil {
 		return ctrl.Result{}, err
 	}
-	err = r.ensureShortenerService(ctx)
+	if !meta.IsStatusConditionTrue(svc.Status.Conditions, urlshortenerv1.ConditionReady) || svc.Status.URL == "" {
+		// Watching the ShortenerService brings the ShortURL back once it is ready.
+		return ctrl.Result{}, r.shortenerNotReady(ctx, &shortURL, svc)
+	}
+	api, err := r.shortenerClient(ctx, svc)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	var shortURL urlshortenerv1.ShortURL
-	if err := r.Get(ctx, req.NamespacedName, &shortURL); err != nil {
-		return ctrl.Result{}, client.IgnoreNotFound(err)
+	if !controllerutil.ContainsFinalizer(&shortURL, shortURLFinalizer) {
+		controllerutil.AddFinalizer(&shortURL, shortURLFinalizer)
+		if err := r.Update(ctx, &shortURL); err != nil {
+			return ctrl.Result{}, err
+		}
 	}
 
 	if shortURL.Status.ShortPath == "" {
-		shortenPath, err := shortenURL(shortURL.Spec.TargetURL, shortURL.Spec.ExpireAt)
+		link, err := api.Shorten(ctx, shortener.ShortenRequest{
+			LongURL:      shortURL.Spec.TargetURL,
+			ExpireAt:     expireAt(&shortURL),
+			NotBefore:    notBefore(&shortURL),
+			MaxClicks:    maxClicks(&shortURL),
+			RedirectType: int(shortURL.Spec.RedirectType),
+			ShortURL:     shortURL.Spec.Alias,
+		})
+		if errors.Is(err, shortener.ErrConflict) {
+			return r.rejectAlias(ctx, &shortURL, urlshortenerv1.ReasonAliasConflict, err)
+		}
+		if errors.Is(err, shortener.ErrInvalidShortURL) {
+			return r.rejectAlias(ctx, &shortURL, urlshortenerv1.ReasonInvalidAlias, err)
+		}
+		if errors.Is(err, shortener.ErrInvalid) {
+			return r.rejectLink(ctx, &shortURL, err)
+		}
 		if err != nil {
-			return ctrl.Result{}, err
+			return r.backendError(ctx, &shortURL, err)
 		}
+		shortenPath := link.ShortURL
 
 		shortURL.Status.ShortPath = shortenPath
 		shortURL.Status.ClickCount = 0
 		shortURL.Status.IsValid = "unknown"
+		shortURL.Status.ObservedGeneration = shortURL.Generation
+		setCondition(&shortURL, urlshortenerv1.ConditionRegistered, metav1.ConditionTrue,
+			urlshortenerv1.ReasonRegistered, "Link registered as /"+shortenPath)
 		if err := r.Status().Update(ctx, &shortURL); err != nil {
 			return ctrl.Result{}, err
 		}
+		r.Recorder.Eventf(&shortURL, corev1.EventTypeNormal, "Created",
+			"Registered /%s for %s", shortenPath, shortURL.Spec.TargetURL)
+	} else if shortURL.Status.ObservedGeneration != shortURL.Generation {
+		// The spec changed since the link was registered, so point the
+		// existing short path at the new target and expiry.
+		err := api.Update(ctx, shortURL.Status.ShortPath, shortener.UpdateRequest{
+			LongURL:      shortURL.Spec.TargetURL,
+			ExpireAt:     expireAt(&shortURL),
+			NotBefore:    notBefore(&shortURL),
+			MaxClicks:    maxClicks(&shortURL),
+			RedirectType: int(shortURL.Spec.RedirectType),
+		})
+		if shortener.IsNotFound(err) {
+			err = r.reregisterShortURL(ctx, api, &shortURL)
+		}
+		if err != nil {
+			return r.backendError(ctx, &shortURL, err)
+		}
+		log.Println("Updated short path", shortURL.Status.ShortPath)
+		r.Recorder.Eventf(&shortURL, corev1.EventTypeNormal, "Updated",
+			"Pointed /%s at %s", shortURL.Status.ShortPath, shortURL.Spec.TargetURL)
+
+		shortURL.Status.ObservedGeneration = shortURL.Generation
+	}
+
+	stats, err := api.Stats(ctx, shortURL.Status.ShortPath)
+	if shortener.IsNotFound(err) {
+		if err := r.reregisterShortURL(ctx, api, &shortURL); err != nil {
+			return r.backendError(ctx, &shortURL, err)
+		}
+		stats, err = api.Stats(ctx, shortURL.Status.ShortPath)
+	}
+	if err != nil {
+		return r.backendError(ctx, &shortURL, err)
 	}
 
-	clickCnt, err := getClickCount(shortURL.Status.ShortPath)
+	shortURL.Status.ClickCount = stats.ClickCount
+
+	validity, err := api.Validity(ctx, shortURL.Status.ShortPath)
+	if shortener.IsNotFound(err) {
+		if err := r.reregisterShortURL(ctx, api, &shortURL); err != nil {
+			return r.backendError(ctx, &shortURL, err)
+		}
+		validity, err = api.Validity(ctx, shortURL.Status.ShortPath)
+	}
 	if err != nil {
+		return r.backendError(ctx, &shortURL, err)
+	}
+	wasExpired := meta.IsStatusConditionTrue(shortURL.Status.Conditions, urlshortenerv1.ConditionExpired)
+	shortURL.Status.IsValid = strconv.FormatBool(validity.IsValid)
+	shortURL.Status.RemainingClicks = nil
+	if validity.RemainingClicks != nil {
+		remaining := int32(*validity.RemainingClicks)
+		shortURL.Status.RemainingClicks = &remaining
+	}
+	shortURL.Status.URL = publicShortURL(svc, shortURL.Status.ShortPath)
+	setBackendStatus(&shortURL, time.Now())
+	if !wasExpired && meta.IsStatusConditionTrue(shortURL.Status.Conditions, urlshortenerv1.ConditionExpired) {
+		r.Recorder.Eventf(&shortURL, corev1.EventTypeNormal, "Expired",
+			"/%s expired and no longer redirects", shortURL.Status.ShortPath)
+	}
+
+	if err := r.Status().Update(ctx, &shortURL); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	shortURL.Status.ClickCount = clickCnt
+	if shortURL.Status.IsValid == "false" {
+		return r.collectExpired(ctx, &shortURL, time.Now())
+	}
+	return r.nextReconcile(&shortURL, time.Now()), nil
+}
+
+// rejectAlias records on the ShortURL that its alias could not be registered.
+// This is a problem with the spec rather than a transient failure, so it is
+// retried slowly in case the alias is released, instead of backing off with an
+// error.
+func (r *ShortURLReconciler) rejectAlias(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, reason string, cause error,
+) (ctrl.Result, error) {
+	log.Println("Alias", shortURL.Spec.Alias, "was rejected:", cause)
+	r.Recorder.Eventf(shortURL, corev1.EventTypeWarning, reason, "Alias %q was rejected: %v", shortURL.Spec.Alias, cause)
 
-	valid, err := checkURLValidity(shortURL.Status.ShortPath)
-	if err != nil {
+	setCondition(shortURL, urlshortenerv1.ConditionRegistered, metav1.ConditionFalse, reason, cause.Error())
+	setNotReady(shortURL, reason, cause.Error())
+	if err := r.Status().Update(ctx, shortURL); err != nil {
 		return ctrl.Result{}, err
 	}
-	shortURL.Status.IsValid = valid
 
-	if err := r.Status().Update(ctx, &shortURL); err != nil {
+	return ctrl.Result{RequeueAfter: time.Minute}, nil
+}
+
+// rejectLink records on the ShortURL that the backend refused to register it.
+// Only a change to the spec can fix that, which triggers a reconcile anyway,
+// so it is not retried.
+func (r *ShortURLReconciler) rejectLink(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, cause error,
+) (ctrl.Result, error) {
+	log.Println("Link", shortURL.Name, "was rejected:", cause)
+	r.Recorder.Eventf(shortURL, corev1.EventTypeWarning, urlshortenerv1.ReasonRejected, "Link was rejected: %v", cause)
+
+	setCondition(shortURL, urlshortenerv1.ConditionRegistered, metav1.ConditionFalse,
+		urlshortenerv1.ReasonRejected, cause.Error())
+	setNotReady(shortURL, urlshortenerv1.ReasonRejected, cause.Error())
+	return ctrl.Result{}, r.Status().Update(ctx, shortURL)
+}
+
+// shortenerNotFound records on the ShortURL that its ShortenerService does not
+// exist. It is retried slowly in case the ShortenerService is created later.
+func (r *ShortURLReconciler) shortenerNotFound(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL,
+) (ctrl.Result, error) {
+	message := fmt.Sprintf("ShortenerService %q not found", r.shortenerKey(shortURL).Name)
+	r.Recorder.Event(shortURL, corev1.EventTypeWarning, urlshortenerv1.ReasonServiceNotFound, message)
+
+	setNotReady(shortURL, urlshortenerv1.ReasonServiceNotFound, message)
+	if err := r.Status().Update(ctx, shortURL); err != nil {
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
+	return ctrl.Result{RequeueAfter: time.Minute}, nil
+}
+
+// shortenerNotReady records on the ShortURL that its ShortenerService cannot
+// serve links yet.
+func (r *ShortURLReconciler) shortenerNotReady(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, svc *urlshortenerv1.ShortenerService,
+) error {
+	message := fmt.Sprintf("ShortenerService %q is not ready", svc.Name)
+	setNotReady(shortURL, urlshortenerv1.ReasonShortenerNotReady, message)
+	return r.Status().Update(ctx, shortURL)
+}
+
+// backendError records a failed call to the shortener backend on the
+// ShortURL and returns the error so the request is retried with backoff.
+func (r *ShortURLReconciler) backendError(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, cause error,
+) (ctrl.Result, error) {
+	r.Recorder.Event(shortURL, corev1.EventTypeWarning, urlshortenerv1.ReasonBackendError, cause.Error())
+
+	setCondition(shortURL, urlshortenerv1.ConditionBackendReachable, metav1.ConditionFalse,
+		urlshortenerv1.ReasonBackendError, cause.Error())
+	setNotReady(shortURL, urlshortenerv1.ReasonBackendError, cause.Error())
+	if err := r.Status().Update(ctx, shortURL); err != nil {
+		log.Println("Unable to record backend error:", err)
+	}
+
+	return ctrl.Result{}, cause
+}
+
+// reregisterShortURL registers the link again under its existing short path
+// after the shortener backend has forgotten it, e.g. because its pod restarted
+// without persistent storage.
+func (r *ShortURLReconciler) reregisterShortURL(
+	ctx context.Context, api shortener.Client, shortURL *urlshortenerv1.ShortURL,
+) error {
+	log.Println("Shortener backend lost short path", shortURL.Status.ShortPath, "re-registering it")
+
+	_, err := api.Shorten(ctx, shortener.ShortenRequest{
+		LongURL:      shortURL.Spec.TargetURL,
+		ExpireAt:     expireAt(shortURL),
+		NotBefore:    notBefore(shortURL),
+		MaxClicks:    maxClicks(shortURL),
+		RedirectType: int(shortURL.Spec.RedirectType),
+		ShortURL:     shortURL.Status.ShortPath,
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Recorder.Eventf(shortURL, corev1.EventTypeWarning, "Reregistered",
+		"Shortener backend had lost /%s, registered it again", shortURL.Status.ShortPath)
+	return nil
+}
+
+// finalizeShortURL removes the link from the shortener API and releases the
+// finalizer once the backend has confirmed the removal. A nil api means the
+// backend no longer exists, or never served any link.
+func (r *ShortURLReconciler) finalizeShortURL(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, api shortener.Client,
+) error {
+	if !controllerutil.ContainsFinalizer(shortURL, shortURLFinalizer) {
+		return nil
+	}
+
+	if shortURL.Status.ShortPath != "" && api != nil {
+		// A missing link is exactly the state we are trying to reach.
+		err := api.Delete(ctx, shortURL.Status.ShortPath)
+		if err != nil && !shortener.IsNotFound(err) {
+			r.Recorder.Eventf(shortURL, corev1.EventTypeWarning, urlshortenerv1.ReasonBackendError,
+				"Unable to delete /%s: %v", shortURL.Status.ShortPath, err)
+			return err
+		}
+		log.Println("Deleted short path", shortURL.Status.ShortPath)
+		r.Recorder.Eventf(shortURL, corev1.EventTypeNormal, "Deleted",
+			"Removed /%s from the shortener backend", shortURL.Status.ShortPath)
+	}
+
+	controllerutil.RemoveFinalizer(shortURL, shortURLFinalizer)
+	return r.Update(ctx, shortURL)
+}
+
+// shortenerClient returns a client for the shortener API of svc.
+func (r *ShortURLReconciler) shortenerClient(
+	ctx context.Context, svc *urlshortenerv1.ShortenerService,
+) (shortener.Client, error) {
+	endpoint, err := shortenerEndpoint(ctx, r.Client, svc)
+	if err != nil {
+		return nil, err
+	}
+	if r.Shorteners == nil {
+		return shortener.New(endpoint.URL, shortener.Options{APIKey: endpoint.APIKey}), nil
+	}
+	return r.Shorteners(endpoint), nil
+}
+
+// expireAt is the expiry of shortURL in the form the shortener client takes.
+func expireAt(shortURL *urlshortenerv1.ShortURL) *time.Time {
+	if shortURL.Spec.ExpireAt == nil {
+		return nil
+	}
+	return &shortURL.Spec.ExpireAt.Time
+}
+
+// notBefore is the activation time of shortURL in the form the shortener
+// client takes.
+func notBefore(shortURL *urlshortenerv1.ShortURL) *time.Time {
+	if shortURL.Spec.NotBefore == nil {
+		return nil
+	}
+	return &shortURL.Spec.NotBefore.Time
+}
+
+// maxClicks is the click limit of shortURL in the form the shortener client
+// takes.
+func maxClicks(shortURL *urlshortenerv1.ShortURL) int {
+	if shortURL.Spec.MaxClicks == nil {
+		return 0
+	}
+	return int(*shortURL.Spec.MaxClicks)
+}
+
+// shortenerKey is the ShortenerService serving shortURL.
+func (r *ShortURLReconciler) shortenerKey(shortURL *urlshortenerv1.ShortURL) types.NamespacedName {
+	return shortenerKeyFor(shortURL, r.DefaultShortener)
+}
+
+// shortenerKeyFor is the namespaced name of the ShortenerService serving
+// shortURL, which is defaultKey unless it has a serviceRef.
+func shortenerKeyFor(shortURL *urlshortenerv1.ShortURL, defaultKey types.NamespacedName) types.NamespacedName {
+	if shortURL.Spec.ServiceRef == nil {
+		return defaultKey
+	}
+	return types.NamespacedName{Name: shortURL.Spec.ServiceRef.Name, Namespace: shortURL.Namespace}
+}
+
+// shortURLsForShortener lists the ShortURLs served by a ShortenerService, so
+// they are reconciled when it becomes ready or stops being ready.
+func (r *ShortURLReconciler) shortURLsForShortener(ctx context.Context, obj client.Object) []reconcile.Request {
+	var shortURLs urlshortenerv1.ShortURLList
+	key := types.NamespacedName{Name: obj.GetName(), Namespace: obj.GetNamespace()}.String()
+	if err := r.List(ctx, &shortURLs, client.MatchingFields{shortenerIndex: key}); err != nil {
+		log.Println("Unable to list ShortURLs of ShortenerService", key, err)
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(shortURLs.Items))
+	for _, shortURL := range shortURLs.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&shortURL)})
+	}
+	return requests
+}
+
+// shortenerReadinessChanged filters ShortenerService updates down to those
+// that change whether or where its ShortURLs can be served.
+func shortenerReadinessChanged(e event.UpdateEvent) bool {
+	oldSvc, ok := e.ObjectOld.(*urlshortenerv1.ShortenerService)
+	if !ok {
+		return false
+	}
+	newSvc, ok := e.ObjectNew.(*urlshortenerv1.ShortenerService)
+	if !ok {
+		return false
+	}
+	return oldSvc.Status.URL != newSvc.Status.URL ||
+		oldSvc.Status.GRPCAddress != newSvc.Status.GRPCAddress ||
+		oldSvc.Status.APIKeySecret != newSvc.Status.APIKeySecret ||
+		meta.IsStatusConditionTrue(oldSvc.Status.Conditions, urlshortenerv1.ConditionReady) !=
+			meta.IsStatusConditionTrue(newSvc.Status.Conditions, urlshortenerv1.ConditionReady)
+}
+
+// onlyClickCountChanged matches ShortURL updates that just bring the click
+// count and remaining clicks up to date, as the ClickFeed does. Reconciling them would poll the
+// shortener for the count that was just written.
+func onlyClickCountChanged(e event.UpdateEvent) bool {
+	oldURL, ok := e.ObjectOld.(*urlshortenerv1.ShortURL)
+	if !ok {
+		return false
+	}
+	newURL, ok := e.ObjectNew.(*urlshortenerv1.ShortURL)
+	if !ok {
+		return false
+	}
+	if oldURL.Status.ClickCount == newURL.Status.ClickCount {
+		return false
+	}
+	// Running out of clicks makes the link expire, which is worth a look.
+	if remaining := newURL.Status.RemainingClicks; remaining != nil && *remaining == 0 {
+		return false
+	}
+	oldURL = oldURL.DeepCopy()
+	oldURL.Status.ClickCount = newURL.Status.ClickCount
+	oldURL.Status.RemainingClicks = newURL.Status.RemainingClicks
+	return oldURL.Generation == newURL.Generation &&
+		oldURL.DeletionTimestamp.Equal(newURL.DeletionTimestamp) &&
+		equality.Semantic.DeepEqual(oldURL.Finalizers, newURL.Finalizers) &&
+		equality.Semantic.DeepEqual(oldURL.Status, newURL.Status)
+}
+
+// publicShortURL is the full public link of shortPath, or empty if svc has no
+// public URL.
+func publicShortURL(svc *urlshortenerv1.ShortenerService, shortPath string) string {
+	if svc.Spec.PublicURL == "" || shortPath == "" {
+		return ""
+	}
+	return strings.TrimSuffix(svc.Spec.PublicURL, "/") + "/" + shortPath
 }
 
+// shortenerIndex indexes ShortURLs by the namespaced name of the
+// ShortenerService serving them.
+const shortenerIndex = ".spec.serviceRef"
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *ShortURLReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	err := mgr.GetFieldIndexer().IndexField(context.Background(), &urlshortenerv1.ShortURL{}, shortenerIndex,
+		func(obj client.Object) []string {
+			return []string{r.shortenerKey(obj.(*urlshortenerv1.ShortURL)).String()}
+		})
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&urlshortenerv1.ShortURL{}).
+		For(&urlshortenerv1.ShortURL{},
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: func(e event.UpdateEvent) bool {
+				return !onlyClickCountChanged(e)
+			}})).
+		Watches(&urlshortenerv1.ShortenerService{},
+			handler.EnqueueRequestsFromMapFunc(r.shortURLsForShortener),
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: shortenerReadinessChanged})).
 		Named("shorturl").
 		Complete(r)
 }
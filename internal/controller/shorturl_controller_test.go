@@ -18,15 +18,22 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"maps"
 	"net/http"
 	"net/http/httptest"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -44,10 +51,22 @@ var _ = Describe("ShortURL Controller", func() {
 			Namespace: "default",
 		}
 		shorturl := &urlshortenerv1.ShortURL{}
+		shortenerName := types.NamespacedName{Name: "test-default-shortener", Namespace: "default"}
 
 		BeforeEach(func() {
+			By("creating the ShortenerService serving the ShortURL")
+			err := k8sClient.Get(ctx, shortenerName, &urlshortenerv1.ShortenerService{})
+			if err != nil && apierrors.IsNotFound(err) {
+				Expect(k8sClient.Create(ctx, &urlshortenerv1.ShortenerService{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      shortenerName.Name,
+						Namespace: shortenerName.Namespace,
+					},
+				})).To(Succeed())
+			}
+
 			By("creating the custom resource for the Kind ShortURL")
-			err := k8sClient.Get(ctx, typeNamespacedName, shorturl)
+			err = k8sClient.Get(ctx, typeNamespacedName, shorturl)
 			if err != nil && apierrors.IsNotFound(err) {
 				resource := &urlshortenerv1.ShortURL{
 					ObjectMeta: metav1.ObjectMeta{
@@ -65,43 +84,455 @@ var _ = Describe("ShortURL Controller", func() {
 		AfterEach(func() {
 			resource := &urlshortenerv1.ShortURL{}
 			err := k8sClient.Get(ctx, typeNamespacedName, resource)
+			if apierrors.IsNotFound(err) {
+				return
+			}
 			Expect(err).NotTo(HaveOccurred())
 
 			By("cleaning up the ShortURL resource")
+			// No controller runs in the test environment, so drop the finalizer
+			// ourselves to let the API server remove the object.
+			if controllerutil.RemoveFinalizer(resource, shortURLFinalizer) {
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			}
 			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
 		})
 
 		It("should successfully reconcile the resource and update status", func() {
-			fakeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				switch r.URL.Path {
-				case "/valid/testShort":
-					fmt.Fprintf(w, `{"is_valid": "true"}`)
-				case "/count/testShort":
-					fmt.Fprintf(w, `{"click_count": 5}`)
-				default:
-					http.NotFound(w, r)
-				}
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"GET /count/testShort": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"click_count": 5}`)
+				},
 			}))
-			defer fakeServer.Close()
-			ShortenerServiceURL = fakeServer.URL
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
 
 			By("triggering reconciliation")
-			controllerReconciler := &ShortURLReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
-			}
-			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
-				NamespacedName: typeNamespacedName,
-			})
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
 			Expect(err).NotTo(HaveOccurred())
 
-			time.Sleep(15 * time.Second)
-
 			updatedShortURL := &urlshortenerv1.ShortURL{}
 			Expect(k8sClient.Get(ctx, typeNamespacedName, updatedShortURL)).To(Succeed())
 			Expect(updatedShortURL.Status.ShortPath).To(Equal("testShort"))
 			Expect(updatedShortURL.Status.ClickCount).To(Equal(5))
 			Expect(updatedShortURL.Status.IsValid).To(Equal("true"))
 		})
+
+		It("should delete the link from the backend before releasing the finalizer", func() {
+			var deleted atomic.Bool
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"DELETE /links/testShort": func(w http.ResponseWriter, r *http.Request) {
+					deleted.Store(true)
+					w.WriteHeader(http.StatusNoContent)
+				},
+			}))
+			controllerReconciler, recorder := newShortURLReconciler(shortenerName)
+
+			By("reconciling the new resource")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(controllerutil.ContainsFinalizer(resource, shortURLFinalizer)).To(BeTrue())
+
+			By("deleting the resource and reconciling again")
+			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deleted.Load()).To(BeTrue())
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal Created")))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal Deleted")))
+
+			err = k8sClient.Get(ctx, typeNamespacedName, resource)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should authenticate to the shortener with its API key", func() {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-default-shortener-api-key", Namespace: "default"},
+				Data:       map[string][]byte{shortenerAPIKeySecretKey: []byte("secret\nold")},
+			}
+			Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+			DeferCleanup(k8sClient.Delete, ctx, secret)
+
+			shortener := testShortener(nil)
+			serveTestShortener(ctx, shortenerName, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Authorization") != "Bearer secret" {
+					http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+					return
+				}
+				shortener.ServeHTTP(w, r)
+			}))
+
+			svc := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, shortenerName, svc)).To(Succeed())
+			svc.Status.APIKeySecret = secret.Name
+			Expect(k8sClient.Status().Update(ctx, svc)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Get(ctx, shortenerName, svc)).To(Succeed())
+				svc.Status.APIKeySecret = ""
+				Expect(k8sClient.Status().Update(ctx, svc)).To(Succeed())
+			})
+
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ShortPath).To(Equal("testShort"))
+		})
+
+		It("should push spec changes to the backend under the same short path", func() {
+			var updatedTarget atomic.Value
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"PUT /links/testShort": func(w http.ResponseWriter, r *http.Request) {
+					var body map[string]string
+					Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+					updatedTarget.Store(body["long_url"])
+					fmt.Fprint(w, `{"short_url": "testShort"}`)
+				},
+			}))
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+
+			By("registering the link")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			By("changing the target URL")
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.TargetURL = "https://example.com"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedTarget.Load()).To(Equal("https://example.com"))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ShortPath).To(Equal("testShort"))
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+		})
+
+		It("should re-register a short path the backend has forgotten", func() {
+			var known atomic.Bool
+			var requestedPath atomic.Value
+			onlyKnown := func(body string) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					if !known.Load() {
+						http.NotFound(w, r)
+						return
+					}
+					fmt.Fprint(w, body)
+				}
+			}
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"POST /shorten": func(w http.ResponseWriter, r *http.Request) {
+					var body map[string]string
+					Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+					requestedPath.Store(body["short_url"])
+					known.Store(true)
+					fmt.Fprint(w, `{"short_url": "testShort"}`)
+				},
+				"GET /valid/testShort": onlyKnown(`{"is_valid": true}`),
+				"GET /count/testShort": onlyKnown(`{"click_count": 0}`),
+			}))
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+
+			By("registering the link")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(requestedPath.Load()).To(BeEmpty())
+
+			By("simulating a backend restart that lost all links")
+			known.Store(false)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(known.Load()).To(BeTrue())
+			Expect(requestedPath.Load()).To(Equal("testShort"))
+		})
+
+		It("should report a conflicting alias as a condition instead of failing", func() {
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"POST /shorten": func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "Short URL already exists", http.StatusConflict)
+				},
+			}))
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Alias = "spring-sale"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ShortPath).To(BeEmpty())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionRegistered)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(urlshortenerv1.ReasonAliasConflict))
+		})
+
+		It("should tell a rejected alias from other rejections", func() {
+			var rejection atomic.Int32
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"POST /shorten": func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "rejected", int(rejection.Load()))
+				},
+			}))
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.Alias = "shorten"
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+
+			By("reporting a refused alias as an invalid alias")
+			rejection.Store(http.StatusUnprocessableEntity)
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionRegistered)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal(urlshortenerv1.ReasonInvalidAlias))
+
+			By("reporting any other refusal as a rejected link")
+			rejection.Store(http.StatusBadRequest)
+			result, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			condition = meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionRegistered)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(urlshortenerv1.ReasonRejected))
+		})
+
+		It("should set the Ready condition and surface backend errors", func() {
+			var backendUp atomic.Bool
+			backendUp.Store(true)
+			shortener := testShortener(nil)
+			serveTestShortener(ctx, shortenerName, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !backendUp.Load() {
+					http.Error(w, "backend unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				shortener.ServeHTTP(w, r)
+			}))
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+
+			By("reconciling against a healthy backend")
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, urlshortenerv1.ConditionReady)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, urlshortenerv1.ConditionRegistered)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, urlshortenerv1.ConditionExpired)).To(BeTrue())
+
+			By("reconciling while the backend is failing")
+			backendUp.Store(false)
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).To(HaveOccurred())
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionBackendReachable)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(urlshortenerv1.ReasonBackendError))
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, urlshortenerv1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should report a serviceRef to a missing ShortenerService", func() {
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ServiceRef = &urlshortenerv1.ShortenerServiceReference{Name: "missing"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(Equal(time.Minute))
+
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ShortPath).To(BeEmpty())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(urlshortenerv1.ReasonServiceNotFound))
+		})
+
+		It("should wait for its ShortenerService to become ready", func() {
+			svc := &urlshortenerv1.ShortenerService{}
+			Expect(k8sClient.Get(ctx, shortenerName, svc)).To(Succeed())
+			meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+				Type:   urlshortenerv1.ConditionReady,
+				Status: metav1.ConditionFalse,
+				Reason: urlshortenerv1.ReasonDeploymentUnavailable,
+			})
+			Expect(k8sClient.Status().Update(ctx, svc)).To(Succeed())
+
+			controllerReconciler, _ := newShortURLReconciler(shortenerName)
+
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ShortPath).To(BeEmpty())
+			condition := meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionReady)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+			Expect(condition.Reason).To(Equal(urlshortenerv1.ReasonShortenerNotReady))
+		})
+
+		It("should schedule the next reconcile around polls and expiry", func() {
+			now := time.Now()
+			controllerReconciler := &ShortURLReconciler{PollInterval: time.Minute}
+			resource := &urlshortenerv1.ShortURL{}
+
+			By("polling links without expiry at the configured interval")
+			result := controllerReconciler.nextReconcile(resource, now)
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Minute, 6*time.Second))
+
+			By("honoring the interval of the ShortURL")
+			resource.Spec.PollInterval = &metav1.Duration{Duration: time.Hour}
+			result = controllerReconciler.nextReconcile(resource, now)
+			Expect(result.RequeueAfter).To(BeNumerically("~", time.Hour, 6*time.Minute))
+
+			By("checking again right after the link becomes active")
+			resource.Spec.NotBefore = &metav1.Time{Time: now.Add(5 * time.Second)}
+			result = controllerReconciler.nextReconcile(resource, now)
+			Expect(result.RequeueAfter).To(Equal(5*time.Second + expirySlack))
+
+			By("reporting the link as not ready until then")
+			resource.Status.ShortPath = "abc"
+			setBackendStatus(resource, now)
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, urlshortenerv1.ConditionReady).Reason).
+				To(Equal(urlshortenerv1.ReasonNotYetActive))
+			setBackendStatus(resource, now.Add(6*time.Second))
+			Expect(meta.IsStatusConditionTrue(resource.Status.Conditions, urlshortenerv1.ConditionReady)).To(BeTrue())
+			resource.Spec.NotBefore = nil
+
+			By("checking again right after the link expires")
+			resource.Spec.ExpireAt = &metav1.Time{Time: now.Add(10 * time.Second)}
+			result = controllerReconciler.nextReconcile(resource, now)
+			Expect(result.RequeueAfter).To(Equal(10*time.Second + expirySlack))
+
+			By("not polling expired links")
+			resource.Status.IsValid = "false"
+			result = controllerReconciler.nextReconcile(resource, now)
+			Expect(result.Requeue).To(BeFalse())
+			Expect(result.RequeueAfter).To(BeZero())
+		})
+
+		It("should delete expired links under the DeleteAfter policy", func() {
+			serveTestShortener(ctx, shortenerName, testShortener(map[string]http.HandlerFunc{
+				"GET /valid/testShort": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"is_valid": false}`)
+				},
+			}))
+
+			resource := &urlshortenerv1.ShortURL{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			resource.Spec.ExpireAt = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+			resource.Spec.TTLAfterExpiry = &metav1.Duration{}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+
+			controllerReconciler, recorder := newShortURLReconciler(shortenerName)
+
+			By("retaining the link without a policy in a namespace that is not annotated")
+			result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeZero())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.DeletionTimestamp.IsZero()).To(BeTrue())
+
+			By("keeping the link during the grace period")
+			resource.Spec.DeletionPolicy = urlshortenerv1.DeletionPolicyDeleteAfter
+			resource.Spec.TTLAfterExpiry = &metav1.Duration{Duration: time.Hour}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			result, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.RequeueAfter).To(BeNumerically("~", 59*time.Minute, time.Minute))
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.DeletionTimestamp.IsZero()).To(BeTrue())
+
+			By("deleting the link once the grace period has passed")
+			resource.Spec.TTLAfterExpiry = &metav1.Duration{}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: typeNamespacedName})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.DeletionTimestamp.IsZero()).To(BeFalse())
+			Eventually(recorder.Events).Should(Receive(HavePrefix("Normal GarbageCollected")))
+		})
 	})
 })
+
+// serveShortener marks the ShortenerService as ready to serve links at url, as
+// its controller would once the shortener API pods are up.
+func serveShortener(ctx context.Context, key types.NamespacedName, url string) {
+	svc := &urlshortenerv1.ShortenerService{}
+	Expect(k8sClient.Get(ctx, key, svc)).To(Succeed())
+	svc.Status.URL = url
+	meta.SetStatusCondition(&svc.Status.Conditions, metav1.Condition{
+		Type:   urlshortenerv1.ConditionReady,
+		Status: metav1.ConditionTrue,
+		Reason: urlshortenerv1.ReasonDeploymentAvailable,
+	})
+	Expect(k8sClient.Status().Update(ctx, svc)).To(Succeed())
+}
+
+// serveTestShortener serves handler as the shortener API of the
+// ShortenerService until the spec ends.
+func serveTestShortener(ctx context.Context, key types.NamespacedName, handler http.Handler) {
+	server := httptest.NewServer(handler)
+	DeferCleanup(server.Close)
+	serveShortener(ctx, key, server.URL)
+}
+
+// testShortener answers like a shortener that registers every link as
+// "testShort" and reports it as valid and never clicked. routes add to or
+// replace these answers; they are keyed by the same ServeMux patterns.
+func testShortener(routes map[string]http.HandlerFunc) http.Handler {
+	handlers := map[string]http.HandlerFunc{
+		"POST /shorten": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"short_url": "testShort"}`)
+		},
+		"GET /valid/testShort": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"is_valid": true}`)
+		},
+		"GET /count/testShort": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"click_count": 0}`)
+		},
+	}
+	maps.Copy(handlers, routes)
+
+	mux := http.NewServeMux()
+	for pattern, handler := range handlers {
+		mux.HandleFunc(pattern, handler)
+	}
+	return mux
+}
+
+// newShortURLReconciler returns a reconciler using the ShortenerService as
+// the default shortener, along with the recorder of its events.
+func newShortURLReconciler(shortener types.NamespacedName) (*ShortURLReconciler, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(100)
+	return &ShortURLReconciler{
+		Client:           k8sClient,
+		Scheme:           k8sClient.Scheme(),
+		Recorder:         recorder,
+		DefaultShortener: shortener,
+	}, recorder
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+// +kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// collectExpired deletes an expired ShortURL once the grace period of its
+// deletion policy has passed, or schedules a reconcile for that moment. The
+// finalizer then removes the link from the shortener as usual.
+func (r *ShortURLReconciler) collectExpired(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, now time.Time,
+) (ctrl.Result, error) {
+	expiredAt, ok := expiryTime(shortURL)
+	if !ok {
+		return ctrl.Result{}, nil
+	}
+	deleteAt, ok, err := r.deletionTime(ctx, shortURL, expiredAt)
+	if err != nil || !ok {
+		return ctrl.Result{}, err
+	}
+	if now.Before(deleteAt) {
+		return ctrl.Result{RequeueAfter: deleteAt.Sub(now) + expirySlack}, nil
+	}
+
+	r.Recorder.Eventf(shortURL, corev1.EventTypeNormal, "GarbageCollected",
+		"Deleting /%s, which expired at %s", shortURL.Status.ShortPath, expiredAt.UTC().Format(time.RFC3339))
+	return ctrl.Result{}, client.IgnoreNotFound(r.Delete(ctx, shortURL))
+}
+
+// expiryTime returns when shortURL stopped redirecting: its expireAt, or when
+// the operator saw it use up its clicks. It is false for links that have not
+// expired.
+func expiryTime(shortURL *urlshortenerv1.ShortURL) (time.Time, bool) {
+	expired := meta.FindStatusCondition(shortURL.Status.Conditions, urlshortenerv1.ConditionExpired)
+	if expired == nil || expired.Status != metav1.ConditionTrue {
+		return time.Time{}, false
+	}
+	if expireAt := shortURL.Spec.ExpireAt; expireAt != nil && expireAt.Before(&expired.LastTransitionTime) {
+		return expireAt.Time, true
+	}
+	return expired.LastTransitionTime.Time, true
+}
+
+// deletionTime returns when shortURL, which expired at expiredAt, is due for
+// deletion, or false if it is retained. Without a deletion policy it is only
+// deleted when its namespace is annotated. The grace period in the spec takes
+// precedence over the annotated one.
+func (r *ShortURLReconciler) deletionTime(
+	ctx context.Context, shortURL *urlshortenerv1.ShortURL, expiredAt time.Time,
+) (time.Time, bool, error) {
+	policy := shortURL.Spec.DeletionPolicy
+	if policy == urlshortenerv1.DeletionPolicyRetain {
+		return time.Time{}, false, nil
+	}
+
+	ttl := time.Duration(0)
+	if policy == "" || shortURL.Spec.TTLAfterExpiry == nil {
+		nsTTL, annotated, err := r.namespaceTTLAfterExpiry(ctx, shortURL.Namespace)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		if !annotated && policy == "" {
+			return time.Time{}, false, nil
+		}
+		ttl = nsTTL
+	}
+	if shortURL.Spec.TTLAfterExpiry != nil {
+		ttl = shortURL.Spec.TTLAfterExpiry.Duration
+	}
+	return expiredAt.Add(ttl), true, nil
+}
+
+// namespaceTTLAfterExpiry returns the grace period annotated on the namespace
+// and whether it is annotated at all.
+func (r *ShortURLReconciler) namespaceTTLAfterExpiry(ctx context.Context, namespace string) (time.Duration, bool, error) {
+	ns := &corev1.Namespace{}
+	if err := r.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return 0, false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	value, ok := ns.Annotations[urlshortenerv1.TTLAfterExpiryAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+
+	ttl, err := urlshortenerv1.ParseTTL(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid %s annotation on namespace %s: %w",
+			urlshortenerv1.TTLAfterExpiryAnnotation, namespace, err)
+	}
+	return ttl, true, nil
+}
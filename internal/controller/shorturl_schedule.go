@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"math/rand/v2"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+const (
+	// defaultPollInterval is how often click counts are refreshed when
+	// neither the operator nor the ShortURL configure it.
+	defaultPollInterval = time.Minute
+	// pollJitter spreads the polls of links created at the same time, as a
+	// fraction of the interval in either direction.
+	pollJitter = 0.1
+	// expirySlack is added to the expiry and activation times before
+	// checking a link again, so that a slightly early clock in the operator
+	// does not find the link in its previous state.
+	expirySlack = time.Second
+)
+
+// nextReconcile decides when to look at a ShortURL again. Expired links are
+// not polled at all, since nothing can change until their spec does. Other
+// links are polled for click counts, and checked again right when they
+// become active and when they expire.
+func (r *ShortURLReconciler) nextReconcile(shortURL *urlshortenerv1.ShortURL, now time.Time) ctrl.Result {
+	if shortURL.Status.IsValid == "false" {
+		return ctrl.Result{}
+	}
+
+	after := jitter(r.pollInterval(shortURL))
+	if notBefore := shortURL.Spec.NotBefore; notBefore != nil && now.Before(notBefore.Time) {
+		after = min(after, notBefore.Sub(now)+expirySlack)
+	}
+	if expireAt := shortURL.Spec.ExpireAt; expireAt != nil {
+		untilExpiry := expireAt.Sub(now) + expirySlack
+		if untilExpiry < after {
+			after = max(untilExpiry, expirySlack)
+		}
+	}
+	return ctrl.Result{RequeueAfter: after}
+}
+
+// pollInterval is how often the click count of shortURL is refreshed.
+func (r *ShortURLReconciler) pollInterval(shortURL *urlshortenerv1.ShortURL) time.Duration {
+	if interval := shortURL.Spec.PollInterval; interval != nil && interval.Duration > 0 {
+		return interval.Duration
+	}
+	if r.PollInterval > 0 {
+		return r.PollInterval
+	}
+	return defaultPollInterval
+}
+
+// jitter randomizes d by up to pollJitter in either direction.
+func jitter(d time.Duration) time.Duration {
+	return time.Duration(float64(d) * (1 + pollJitter*(2*rand.Float64()-1)))
+}
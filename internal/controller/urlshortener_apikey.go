@@ -0,0 +1,136 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+	"urlshortener-operator/internal/shortener"
+)
+
+const (
+	// shortenerAPIKeySecretKey is the key of the API keys in the Secret of a
+	// shortener. Its value holds one key per line; the operator uses the
+	// first one.
+	shortenerAPIKeySecretKey = "api-key"
+	// shortenerAPIKeyPath is where the Secret is mounted in the shortener
+	// API pods.
+	shortenerAPIKeyPath = "/etc/urlshortener/auth"
+	// shortenerAPIKeyLabel marks the Secrets holding the API keys of the
+	// shorteners.
+	shortenerAPIKeyLabel = "urlshortener.shortener.io/api-key"
+)
+
+// APIKeySecretSelector selects the Secrets holding the API keys of the
+// shorteners, which are the only Secrets the operator reads. The manager
+// caches just these rather than every Secret in the cluster.
+var APIKeySecretSelector = labels.SelectorFromSet(labels.Set{shortenerAPIKeyLabel: "true"})
+
+// shortenerAPIKeySecretName is the name of the Secret holding the API key of
+// svc.
+func shortenerAPIKeySecretName(svc *urlshortenerv1.ShortenerService) string {
+	return svc.Name + "-api-key"
+}
+
+// ensureShortenerAPIKey applies the Secret holding the API key of svc. The
+// key is generated when the Secret is created and kept afterwards, so that
+// it can be rotated by editing the Secret.
+func ensureShortenerAPIKey(ctx context.Context, c client.Client, svc *urlshortenerv1.ShortenerService) error {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      shortenerAPIKeySecretName(svc),
+			Namespace: svc.Namespace,
+			Labels:    shortenerLabels(svc),
+		},
+		Type: corev1.SecretTypeOpaque,
+	}
+	secret.Labels[shortenerAPIKeyLabel] = "true"
+
+	existing := &corev1.Secret{}
+	err := c.Get(ctx, client.ObjectKeyFromObject(secret), existing)
+	if apierrors.IsNotFound(err) {
+		// Create rather than apply the new key: an apply from a reconcile
+		// whose cache has not seen the Secret yet would replace the key.
+		apiKey, err := generateAPIKey()
+		if err != nil {
+			return err
+		}
+		secret.Data = map[string][]byte{shortenerAPIKeySecretKey: []byte(apiKey)}
+		if err := controllerutil.SetControllerReference(svc, secret, c.Scheme()); err != nil {
+			return err
+		}
+		err = c.Create(ctx, secret, fieldOwner)
+		if apierrors.IsAlreadyExists(err) {
+			// The Secret is not in the cache yet, or it lacks the label the
+			// cache selects Secrets by, e.g. because the user created it.
+			// Label it so that the cache picks it up.
+			patch := fmt.Sprintf(`{"metadata":{"labels":{%q:"true"}}}`, shortenerAPIKeyLabel)
+			return c.Patch(ctx, secret, client.RawPatch(types.MergePatchType, []byte(patch)), fieldOwner)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	apiKeys := existing.Data[shortenerAPIKeySecretKey]
+	if firstAPIKey(apiKeys) == "" {
+		return fmt.Errorf("secret %s has no API key under %q", secret.Name, shortenerAPIKeySecretKey)
+	}
+	secret.Data = map[string][]byte{shortenerAPIKeySecretKey: apiKeys}
+	return applyShortenerObject(ctx, c, svc, secret)
+}
+
+// generateAPIKey returns a random key of 256 bits.
+func generateAPIKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(key), nil
+}
+
+// firstAPIKey is the key the operator uses out of the keys of a Secret.
+func firstAPIKey(apiKeys []byte) string {
+	for _, line := range strings.Split(string(apiKeys), "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			return key
+		}
+	}
+	return ""
+}
+
+// shortenerEndpoint is where the APIs of the shortener of svc are served,
+// with the API key they require.
+func shortenerEndpoint(
+	ctx context.Context, c client.Reader, svc *urlshortenerv1.ShortenerService,
+) (shortener.Endpoint, error) {
+	endpoint := shortener.Endpoint{URL: svc.Status.URL, GRPCAddress: svc.Status.GRPCAddress}
+	if svc.Status.APIKeySecret == "" {
+		// Shorteners set up by earlier versions of the operator have no key.
+		return endpoint, nil
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, client.ObjectKey{Name: svc.Status.APIKeySecret, Namespace: svc.Namespace}, secret)
+	if err != nil {
+		return endpoint, fmt.Errorf("reading API key of ShortenerService %s: %w", svc.Name, err)
+	}
+	endpoint.APIKey = firstAPIKey(secret.Data[shortenerAPIKeySecretKey])
+	return endpoint, nil
+}
@@ -5,53 +5,140 @@ import (
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/utils/pointer"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
 )
 
-// ensureShortenerDeployment creates the Deployment for the shortener API if it does not exist.
-func (r *ShortURLReconciler) ensureShortenerDeployment(ctx context.Context) error {
-	deployment := &appsv1.Deployment{}
-	err := r.Get(ctx, client.ObjectKey{Name: "urlshortener-api", Namespace: "urlshortener-operator-system"}, deployment)
-	if err != nil && apierrors.IsNotFound(err) {
-		deployment = &appsv1.Deployment{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "urlshortener-api",
-				Namespace: "urlshortener-operator-system",
-				Labels:    map[string]string{"app": "urlshortener-api"},
+// shortenerContainerPort is the port the shortener API listens on inside its pod.
+const shortenerContainerPort = 8080
+
+// shortenerGRPCContainerPort is the port the gRPC API of the shortener listens
+// on inside its pod.
+const shortenerGRPCContainerPort = 50051
+
+// ensureShortenerDeployment applies the desired Deployment of the shortener API,
+// reverting any drift in the fields the operator manages.
+func ensureShortenerDeployment(ctx context.Context, c client.Client, svc *urlshortenerv1.ShortenerService) error {
+	labels := shortenerLabels(svc)
+	replicas := svc.Spec.Replicas
+	if replicas == nil {
+		replicas = ptr.To(int32(1))
+	}
+
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
 			},
-			Spec: appsv1.DeploymentSpec{
-				Replicas: pointer.Int32Ptr(1),
-				Selector: &metav1.LabelSelector{
-					MatchLabels: map[string]string{"app": "urlshortener-api"},
+			// The strategy is set in full, with the server defaults, so that
+			// the operator owns rollingUpdate and it is dropped when storage
+			// switches the strategy to Recreate.
+			Strategy: appsv1.DeploymentStrategy{
+				Type: appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{
+					MaxUnavailable: ptr.To(intstr.FromString("25%")),
+					MaxSurge:       ptr.To(intstr.FromString("25%")),
 				},
-				Template: corev1.PodTemplateSpec{
-					ObjectMeta: metav1.ObjectMeta{
-						Labels: map[string]string{"app": "urlshortener-api"},
-					},
-					Spec: corev1.PodSpec{
-						Containers: []corev1.Container{
-							{
-								Name:  "urlshortener-api",
-								Image: "docker.io/sadegh81/url-shortener:v2",
-								Ports: []corev1.ContainerPort{
-									{
-										ContainerPort: 8080,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					// The Secret is mounted rather than passed in the
+					// environment, so that a changed key reaches the running
+					// pods.
+					Volumes: []corev1.Volume{
+						{
+							Name: "api-key",
+							VolumeSource: corev1.VolumeSource{
+								Secret: &corev1.SecretVolumeSource{
+									SecretName: shortenerAPIKeySecretName(svc),
+									Items: []corev1.KeyToPath{
+										{Key: shortenerAPIKeySecretKey, Path: "api-keys"},
 									},
 								},
 							},
 						},
 					},
+					Containers: []corev1.Container{
+						{
+							Name:      "urlshortener-api",
+							Image:     svc.Spec.Image,
+							Resources: svc.Spec.Resources,
+							Ports: []corev1.ContainerPort{
+								{
+									Name:          "http",
+									ContainerPort: shortenerContainerPort,
+									Protocol:      corev1.ProtocolTCP,
+								},
+								{
+									Name:          "grpc",
+									ContainerPort: shortenerGRPCContainerPort,
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							Env: []corev1.EnvVar{
+								{Name: "API_KEYS_FILE", Value: shortenerAPIKeyPath + "/api-keys"},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									Name:      "api-key",
+									MountPath: shortenerAPIKeyPath,
+									ReadOnly:  true,
+								},
+							},
+						},
+					},
 				},
 			},
-		}
-		if err := r.Create(ctx, deployment); err != nil {
-			return err
-		}
-	} else if err != nil {
-		return err
+		},
+	}
+	if svc.Spec.Storage != nil {
+		withPersistentStorage(deployment, shortenerClaimName(svc))
 	}
-	return nil
+
+	return applyShortenerObject(ctx, c, svc, deployment)
+}
+
+// withPersistentStorage switches the shortener API to the on-disk bolt storage
+// and mounts the data PersistentVolumeClaim into it.
+func withPersistentStorage(deployment *appsv1.Deployment, claimName string) {
+	// The database file is locked by a single writer, so never run the old
+	// and new pod side by side during a rollout.
+	deployment.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+
+	podSpec := &deployment.Spec.Template.Spec
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: "data",
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: claimName,
+			},
+		},
+	})
+
+	container := &podSpec.Containers[0]
+	container.Env = append(container.Env,
+		corev1.EnvVar{Name: "STORAGE_BACKEND", Value: "bolt"},
+		corev1.EnvVar{Name: "STORAGE_PATH", Value: shortenerDataPath + "/urlshortener.db"},
+	)
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      "data",
+		MountPath: shortenerDataPath,
+	})
 }
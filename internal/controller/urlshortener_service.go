@@ -7,36 +7,88 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
 )
 
-// ensureShortenerService creates the Service for the shortener API if it does not exist.
-func (r *ShortURLReconciler) ensureShortenerService(ctx context.Context) error {
-	service := &corev1.Service{}
-	err := r.Get(ctx, client.ObjectKey{Name: "urlshortener-api", Namespace: "urlshortener-operator-system"}, service)
-	if err != nil && apierrors.IsNotFound(err) {
-		service = &corev1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      "urlshortener-api",
-				Namespace: "urlshortener-operator-system",
-			},
-			Spec: corev1.ServiceSpec{
-				Selector: map[string]string{"app": "urlshortener-api"},
-				Ports: []corev1.ServicePort{
-					{
-						Port:       8080,
-						TargetPort: intstr.FromInt(8080),
-						Protocol:   corev1.ProtocolTCP,
-					},
+// ensureShortenerService applies the desired Service of the shortener API,
+// reverting any drift in the fields the operator manages.
+func ensureShortenerService(ctx context.Context, c client.Client, svc *urlshortenerv1.ShortenerService) error {
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      svc.Name,
+			Namespace: svc.Namespace,
+			Labels:    shortenerLabels(svc),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: shortenerLabels(svc),
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       shortenerServicePort(svc),
+					TargetPort: intstr.FromInt(shortenerContainerPort),
+					Protocol:   corev1.ProtocolTCP,
+				},
+				{
+					Name:        "grpc",
+					Port:        shortenerGRPCServicePort(svc),
+					TargetPort:  intstr.FromInt(shortenerGRPCContainerPort),
+					Protocol:    corev1.ProtocolTCP,
+					AppProtocol: ptr.To("grpc"),
 				},
-				Type: corev1.ServiceTypeClusterIP,
 			},
+			Type: corev1.ServiceTypeClusterIP,
+		},
+	}
+
+	if err := removeForeignServicePorts(ctx, c, service); err != nil {
+		return err
+	}
+	return applyShortenerObject(ctx, c, svc, service)
+}
+
+// removeForeignServicePorts replaces the ports of the existing Service with
+// the desired ones when it has ports the operator does not set. Service ports
+// are a map keyed on port and protocol, so an apply would keep a renumbered
+// port next to the desired one, and the two would clash on their name.
+func removeForeignServicePorts(ctx context.Context, c client.Client, desired *corev1.Service) error {
+	existing := &corev1.Service{}
+	err := c.Get(ctx, client.ObjectKeyFromObject(desired), existing)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	foreign := false
+	for _, port := range existing.Spec.Ports {
+		if !hasServicePort(desired.Spec.Ports, port) {
+			foreign = true
 		}
-		if err := r.Create(ctx, service); err != nil {
-			return err
+	}
+	if !foreign {
+		return nil
+	}
+
+	patch := client.MergeFromWithOptions(existing.DeepCopy(), client.MergeFromWithOptimisticLock{})
+	existing.Spec.Ports = desired.Spec.Ports
+	return c.Patch(ctx, existing, patch, fieldOwner)
+}
+
+// hasServicePort reports whether ports has an entry with the port and
+// protocol of port.
+func hasServicePort(ports []corev1.ServicePort, port corev1.ServicePort) bool {
+	for _, p := range ports {
+		if p.Port == port.Port && p.Protocol == port.Protocol {
+			return true
 		}
-	} else if err != nil {
-		return err
 	}
-	return nil
+	return false
 }
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+// shortenerDataPath is where the shortener API keeps its database when
+// persistent storage is enabled.
+const shortenerDataPath = "/data"
+
+// ensureShortenerStorage applies the PersistentVolumeClaim backing the shortener
+// API database if persistent storage is enabled.
+func ensureShortenerStorage(ctx context.Context, c client.Client, svc *urlshortenerv1.ShortenerService) error {
+	storage := svc.Spec.Storage
+	if storage == nil {
+		return nil
+	}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "PersistentVolumeClaim",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      shortenerClaimName(svc),
+			Namespace: svc.Namespace,
+			Labels:    shortenerLabels(svc),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: storage.Size,
+				},
+			},
+		},
+	}
+	if storage.StorageClassName != "" {
+		pvc.Spec.StorageClassName = &storage.StorageClassName
+	}
+
+	return applyShortenerObject(ctx, c, svc, pvc)
+}
@@ -0,0 +1,353 @@
+// Package shortener is a client for the HTTP and gRPC APIs of the
+// urlshortener-app.
+package shortener
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to one shortener API.
+type Client interface {
+	// Shorten registers a link and returns it with its short path.
+	Shorten(ctx context.Context, req ShortenRequest) (*Link, error)
+	// Get returns the link registered under shortURL.
+	Get(ctx context.Context, shortURL string) (*Link, error)
+	// Update points an existing short path at a new target and expiry.
+	Update(ctx context.Context, shortURL string, req UpdateRequest) error
+	// Delete removes the link registered under shortURL.
+	Delete(ctx context.Context, shortURL string) error
+	// Stats returns the usage of a link.
+	Stats(ctx context.Context, shortURL string) (*Stats, error)
+	// Validity reports whether a link still redirects.
+	Validity(ctx context.Context, shortURL string) (*Validity, error)
+	// WatchClicks subscribes to the click events of the shortener and calls
+	// fn with each batch. It blocks until ctx is done or the stream breaks.
+	WatchClicks(ctx context.Context, fn func([]ClickEvent)) error
+}
+
+// ShortenRequest is the body of a request to register a link.
+type ShortenRequest struct {
+	LongURL  string     `json:"long_url"`
+	ExpireAt *time.Time `json:"-"`
+	// NotBefore is when the link starts to redirect.
+	NotBefore *time.Time `json:"-"`
+	// MaxClicks limits how often the link redirects. Zero means no limit.
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// RedirectType is the status code of the redirect: 301, 302, 307 or
+	// 308. Zero leaves the choice to the shortener.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// ShortURL requests a specific short path instead of a generated one.
+	ShortURL string `json:"short_url,omitempty"`
+}
+
+// UpdateRequest is the body of a request to change a link.
+type UpdateRequest struct {
+	LongURL      string     `json:"long_url"`
+	ExpireAt     *time.Time `json:"-"`
+	NotBefore    *time.Time `json:"-"`
+	MaxClicks    int        `json:"max_clicks,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+}
+
+// Link is a short path and the target it redirects to.
+type Link struct {
+	ShortURL string     `json:"short_url"`
+	LongURL  string     `json:"long_url"`
+	ExpireAt *time.Time `json:"expire_at,omitempty"`
+	// NotBefore is when the link starts to redirect.
+	NotBefore    *time.Time `json:"not_before,omitempty"`
+	MaxClicks    int        `json:"max_clicks,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+}
+
+// Stats is the usage of a link.
+type Stats struct {
+	ClickCount int `json:"click_count"`
+}
+
+// Validity tells whether a link still redirects.
+type Validity struct {
+	IsValid bool `json:"is_valid"`
+	// RemainingClicks is how often a link with a click limit can still be
+	// followed. It is nil for links without a limit.
+	RemainingClicks *int `json:"remaining_clicks,omitempty"`
+}
+
+// ClickEvent reports the clicks a link got since the previous event.
+type ClickEvent struct {
+	ShortURL string `json:"short_url"`
+	// Clicks is the number of clicks since the previous event of the link.
+	Clicks int `json:"clicks"`
+	// ClickCount is the total click count of the link.
+	ClickCount int `json:"click_count"`
+}
+
+// Options tune a client. The zero value gives sensible defaults.
+type Options struct {
+	// HTTPClient sends the requests of HTTP clients. Timeout is used when it
+	// is nil.
+	HTTPClient *http.Client
+	// Timeout bounds each attempt of a request. Defaults to 10 seconds.
+	Timeout time.Duration
+	// MaxRetries is how often a request that failed with ErrUnavailable is
+	// retried. Defaults to 3; a negative value disables retries.
+	MaxRetries int
+	// RetryBackoff is the base of the exponential backoff between retries.
+	// The actual wait is drawn at random up to the current backoff. Defaults
+	// to 200 milliseconds.
+	RetryBackoff time.Duration
+	// APIKey is sent as a bearer token with every request. Shorteners
+	// without API keys ignore it.
+	APIKey string
+}
+
+// Endpoint is where a shortener serves its APIs.
+type Endpoint struct {
+	// URL is the base URL of the HTTP API.
+	URL string
+	// GRPCAddress is the host:port of the gRPC API. It is empty for
+	// shorteners that do not serve one.
+	GRPCAddress string
+	// APIKey authenticates the client to the shortener. It is empty for
+	// shorteners that do not require one.
+	APIKey string
+}
+
+// Factory creates a client for the shortener at endpoint.
+type Factory func(endpoint Endpoint) Client
+
+// NewFactory returns a Factory creating HTTP clients with opts.
+func NewFactory(opts Options) Factory {
+	return func(endpoint Endpoint) Client {
+		return New(endpoint.URL, opts.forEndpoint(endpoint))
+	}
+}
+
+// forEndpoint returns opts with the API key of endpoint.
+func (opts Options) forEndpoint(endpoint Endpoint) Options {
+	opts.APIKey = endpoint.APIKey
+	return opts
+}
+
+// HTTPClient is the Client for the urlshortener-app HTTP API.
+type HTTPClient struct {
+	baseURL      string
+	apiKey       string
+	httpClient   *http.Client
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+var _ Client = &HTTPClient{}
+
+// New returns a client for the shortener API at baseURL.
+func New(baseURL string, opts Options) *HTTPClient {
+	c := &HTTPClient{
+		baseURL:      strings.TrimSuffix(baseURL, "/"),
+		apiKey:       opts.APIKey,
+		httpClient:   opts.HTTPClient,
+		maxRetries:   opts.MaxRetries,
+		retryBackoff: opts.RetryBackoff,
+	}
+	if c.httpClient == nil {
+		timeout := opts.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		c.httpClient = &http.Client{Timeout: timeout}
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = 3
+	}
+	if c.retryBackoff == 0 {
+		c.retryBackoff = 200 * time.Millisecond
+	}
+	return c
+}
+
+// Shorten is not retried: the request is not idempotent, and a retry of a
+// request that did reach the backend would register a second link.
+func (c *HTTPClient) Shorten(ctx context.Context, req ShortenRequest) (*Link, error) {
+	body := linkBody{
+		LongURL:      req.LongURL,
+		ExpireAt:     formatTime(req.ExpireAt),
+		NotBefore:    formatTime(req.NotBefore),
+		MaxClicks:    req.MaxClicks,
+		RedirectType: req.RedirectType,
+		ShortURL:     req.ShortURL,
+	}
+	var resp struct {
+		ShortURL string `json:"short_url"`
+	}
+	if err := c.do(ctx, false, http.MethodPost, "/shorten", body, &resp); err != nil {
+		return nil, err
+	}
+	return &Link{
+		ShortURL:     resp.ShortURL,
+		LongURL:      req.LongURL,
+		ExpireAt:     req.ExpireAt,
+		NotBefore:    req.NotBefore,
+		MaxClicks:    req.MaxClicks,
+		RedirectType: req.RedirectType,
+	}, nil
+}
+
+func (c *HTTPClient) Get(ctx context.Context, shortURL string) (*Link, error) {
+	var link Link
+	if err := c.do(ctx, true, http.MethodGet, "/links/"+url.PathEscape(shortURL), nil, &link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+func (c *HTTPClient) Update(ctx context.Context, shortURL string, req UpdateRequest) error {
+	body := linkBody{
+		LongURL:      req.LongURL,
+		ExpireAt:     formatTime(req.ExpireAt),
+		NotBefore:    formatTime(req.NotBefore),
+		MaxClicks:    req.MaxClicks,
+		RedirectType: req.RedirectType,
+	}
+	return c.do(ctx, true, http.MethodPut, "/links/"+url.PathEscape(shortURL), body, nil)
+}
+
+func (c *HTTPClient) Delete(ctx context.Context, shortURL string) error {
+	return c.do(ctx, true, http.MethodDelete, "/links/"+url.PathEscape(shortURL), nil, nil)
+}
+
+func (c *HTTPClient) Stats(ctx context.Context, shortURL string) (*Stats, error) {
+	var stats Stats
+	if err := c.do(ctx, true, http.MethodGet, "/count/"+url.PathEscape(shortURL), nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (c *HTTPClient) Validity(ctx context.Context, shortURL string) (*Validity, error) {
+	// Decode into a pointer to tell a missing field from false.
+	var resp struct {
+		IsValid         *bool `json:"is_valid"`
+		RemainingClicks *int  `json:"remaining_clicks"`
+	}
+	if err := c.do(ctx, true, http.MethodGet, "/valid/"+url.PathEscape(shortURL), nil, &resp); err != nil {
+		return nil, err
+	}
+	if resp.IsValid == nil {
+		return nil, fmt.Errorf("validity of %q: response has no is_valid field", shortURL)
+	}
+	return &Validity{IsValid: *resp.IsValid, RemainingClicks: resp.RemainingClicks}, nil
+}
+
+// linkBody is the wire format of ShortenRequest and UpdateRequest.
+type linkBody struct {
+	LongURL      string `json:"long_url"`
+	ExpireAt     string `json:"expire_at,omitempty"`
+	NotBefore    string `json:"not_before,omitempty"`
+	MaxClicks    int    `json:"max_clicks,omitempty"`
+	RedirectType int    `json:"redirect_type,omitempty"`
+	ShortURL     string `json:"short_url,omitempty"`
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// do sends a request with a JSON body, decodes a JSON response into out and
+// maps error statuses to *Error. Requests failing with ErrUnavailable are
+// retried with jittered exponential backoff if retry is set.
+func (c *HTTPClient) do(ctx context.Context, retry bool, method, path string, in, out any) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+
+	maxRetries := c.maxRetries
+	if !retry {
+		maxRetries = 0
+	}
+	return withRetries(ctx, maxRetries, c.retryBackoff, func() error {
+		return c.attempt(ctx, method, path, payload, out)
+	})
+}
+
+// withRetries calls attempt, retrying it up to maxRetries times while it
+// fails with ErrUnavailable. The waits in between grow exponentially from
+// backoff, with jitter.
+func withRetries(ctx context.Context, maxRetries int, backoff time.Duration, attempt func() error) error {
+	for n := 0; ; n++ {
+		err := attempt()
+		if err == nil || n >= maxRetries || !IsUnavailable(err) {
+			return err
+		}
+
+		wait := time.Duration(rand.Int64N(int64(backoff))) + 1
+		backoff *= 2
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+	}
+}
+
+// authorize adds the API key of the client to req.
+func (c *HTTPClient) authorize(req *http.Request) {
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+}
+
+func (c *HTTPClient) attempt(ctx context.Context, method, path string, payload []byte, out any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	c.authorize(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return &Error{Method: method, Path: path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &Error{
+			Method:     method,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
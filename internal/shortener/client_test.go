@@ -0,0 +1,218 @@
+package shortener
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		ctx     context.Context
+		server  *httptest.Server
+		handler http.HandlerFunc
+		client  *HTTPClient
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r)
+		}))
+		client = New(server.URL, Options{RetryBackoff: time.Millisecond})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("should register links", func() {
+		expireAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal(http.MethodPost))
+			Expect(r.URL.Path).To(Equal("/shorten"))
+
+			var body map[string]string
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			Expect(body).To(Equal(map[string]string{
+				"long_url":  "https://example.com",
+				"expire_at": "2030-01-02T02:04:05Z",
+				"short_url": "example",
+			}))
+			fmt.Fprint(w, `{"short_url": "example"}`)
+		}
+
+		link, err := client.Shorten(ctx, ShortenRequest{
+			LongURL:  "https://example.com",
+			ExpireAt: &expireAt,
+			ShortURL: "example",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.ShortURL).To(Equal("example"))
+	})
+
+	It("should decode stats and validity", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/count/abc":
+				fmt.Fprint(w, `{"click_count": 7}`)
+			case "/valid/abc":
+				fmt.Fprint(w, `{"is_valid": false}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}
+
+		stats, err := client.Stats(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.ClickCount).To(Equal(7))
+
+		validity, err := client.Validity(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(validity.IsValid).To(BeFalse())
+	})
+
+	It("should send its API key", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, `{"click_count": 7}`)
+		}
+
+		_, err := client.Stats(ctx, "abc")
+		Expect(errors.Is(err, ErrUnauthorized)).To(BeTrue(), "got %v", err)
+
+		client = New(server.URL, Options{APIKey: "secret"})
+		_, err = client.Stats(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should reject a validity response without is_valid", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{}`)
+		}
+
+		_, err := client.Validity(ctx, "abc")
+		Expect(err).To(HaveOccurred())
+	})
+
+	DescribeTable("should map error statuses to typed errors",
+		func(status int, target error) {
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "nope", status)
+			}
+
+			err := client.Update(ctx, "abc", UpdateRequest{LongURL: "https://example.com"})
+			Expect(errors.Is(err, target)).To(BeTrue(), "got %v", err)
+
+			var clientErr *Error
+			Expect(errors.As(err, &clientErr)).To(BeTrue())
+			Expect(clientErr.StatusCode).To(Equal(status))
+			Expect(clientErr.Message).To(Equal("nope"))
+		},
+		Entry("not found", http.StatusNotFound, ErrNotFound),
+		Entry("conflict", http.StatusConflict, ErrConflict),
+		Entry("unprocessable", http.StatusUnprocessableEntity, ErrInvalid),
+		Entry("unprocessable short URL", http.StatusUnprocessableEntity, ErrInvalidShortURL),
+		Entry("bad request", http.StatusBadRequest, ErrInvalid),
+		Entry("unauthorized", http.StatusUnauthorized, ErrUnauthorized),
+		Entry("server error", http.StatusInternalServerError, ErrUnavailable),
+		Entry("too many requests", http.StatusTooManyRequests, ErrUnavailable),
+	)
+
+	It("should only blame the short URL for 422 Unprocessable Entity", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Invalid long_url: must be an absolute URL", http.StatusBadRequest)
+		}
+
+		_, err := client.Shorten(ctx, ShortenRequest{LongURL: "example.com", ShortURL: "spring-sale"})
+		Expect(errors.Is(err, ErrInvalid)).To(BeTrue())
+		Expect(errors.Is(err, ErrInvalidShortURL)).To(BeFalse())
+	})
+
+	It("should retry idempotent requests while the shortener is unavailable", func() {
+		var calls atomic.Int32
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				http.Error(w, "starting up", http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+		Expect(client.Delete(ctx, "abc")).To(Succeed())
+		Expect(calls.Load()).To(Equal(int32(3)))
+	})
+
+	It("should give up after the configured retries", func() {
+		var calls atomic.Int32
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+
+		client = New(server.URL, Options{MaxRetries: 2, RetryBackoff: time.Millisecond})
+		_, err := client.Stats(ctx, "abc")
+		Expect(IsUnavailable(err)).To(BeTrue())
+		Expect(calls.Load()).To(Equal(int32(3)))
+	})
+
+	It("should not retry registering a link", func() {
+		var calls atomic.Int32
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+
+		_, err := client.Shorten(ctx, ShortenRequest{LongURL: "https://example.com"})
+		Expect(IsUnavailable(err)).To(BeTrue())
+		Expect(calls.Load()).To(Equal(int32(1)))
+	})
+
+	It("should report unreachable shorteners as unavailable", func() {
+		server.Close()
+
+		_, err := New(server.URL, Options{MaxRetries: -1}).Get(ctx, "abc")
+		Expect(IsUnavailable(err)).To(BeTrue())
+	})
+
+	It("should stop waiting when the context is done", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+		defer cancel()
+		_, err := client.Get(ctx, "abc")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should stream click events", func() {
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.URL.Path).To(Equal("/events"))
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": connected\n\n")
+			fmt.Fprint(w, "event: other\ndata: ignored\n\n")
+			fmt.Fprint(w, "event: clicks\ndata: [{\"short_url\":\"a\",\"clicks\":2,\"click_count\":5}]\n\n")
+		}
+
+		var batches [][]ClickEvent
+		err := client.WatchClicks(ctx, func(batch []ClickEvent) {
+			batches = append(batches, batch)
+		})
+		Expect(IsUnavailable(err)).To(BeTrue(), "a closed stream should be reported as unavailable")
+		Expect(batches).To(Equal([][]ClickEvent{{{ShortURL: "a", Clicks: 2, ClickCount: 5}}}))
+	})
+})
@@ -0,0 +1,321 @@
+package shortener
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+	"k8s.io/kube-openapi/pkg/validation/strfmt"
+	"k8s.io/kube-openapi/pkg/validation/validate"
+)
+
+// openAPIPath is the OpenAPI document served by the shortener.
+const openAPIPath = "../../urlshortener-app/handlers/openapi.json"
+
+// contractAPIKey is the API key of the client checked against the document.
+const contractAPIKey = "contract-key"
+
+// The client is checked against a fake shortener generated from the OpenAPI
+// document: requests must match a documented operation, its security
+// requirements and its request body schema, and are answered with the documented example of the operation,
+// which must match its response schema.
+var _ = Describe("Client contract", func() {
+	var (
+		ctx    context.Context
+		doc    *spec3.OpenAPI
+		server *httptest.Server
+		client *HTTPClient
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		data, err := os.ReadFile(openAPIPath)
+		Expect(err).NotTo(HaveOccurred())
+		doc = &spec3.OpenAPI{}
+		Expect(json.Unmarshal(data, doc)).To(Succeed())
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			serveExample(doc, w, r)
+		}))
+		client = New(server.URL, Options{MaxRetries: -1, APIKey: contractAPIKey})
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	expireAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+
+	DescribeTable("should send documented requests and decode the documented responses",
+		func(call func(*HTTPClient) error) {
+			Expect(call(client)).To(Succeed())
+		},
+		Entry("shorten", func(c *HTTPClient) error {
+			link, err := c.Shorten(ctx, ShortenRequest{
+				LongURL:      "https://example.com",
+				ExpireAt:     &expireAt,
+				NotBefore:    &expireAt,
+				MaxClicks:    3,
+				RedirectType: http.StatusMovedPermanently,
+				ShortURL:     "example",
+			})
+			if err == nil && link.ShortURL == "" {
+				err = fmt.Errorf("no short URL in %+v", link)
+			}
+			return err
+		}),
+		Entry("get", func(c *HTTPClient) error {
+			link, err := c.Get(ctx, "aZ3kP9")
+			if err == nil && (link.LongURL == "" || link.ExpireAt == nil || link.RedirectType == 0) {
+				err = fmt.Errorf("link not fully decoded: %+v", link)
+			}
+			return err
+		}),
+		Entry("update", func(c *HTTPClient) error {
+			return c.Update(ctx, "aZ3kP9", UpdateRequest{LongURL: "https://example.com", ExpireAt: &expireAt})
+		}),
+		Entry("delete", func(c *HTTPClient) error {
+			return c.Delete(ctx, "aZ3kP9")
+		}),
+		Entry("stats", func(c *HTTPClient) error {
+			stats, err := c.Stats(ctx, "aZ3kP9")
+			if err == nil && stats.ClickCount == 0 {
+				err = fmt.Errorf("click count not decoded: %+v", stats)
+			}
+			return err
+		}),
+		Entry("validity", func(c *HTTPClient) error {
+			validity, err := c.Validity(ctx, "aZ3kP9")
+			if err == nil && validity.RemainingClicks == nil {
+				err = fmt.Errorf("remaining clicks not decoded: %+v", validity)
+			}
+			return err
+		}),
+	)
+
+	It("should decode the documented event stream", func() {
+		var batches [][]ClickEvent
+		err := client.WatchClicks(ctx, func(batch []ClickEvent) {
+			batches = append(batches, batch)
+		})
+		Expect(IsUnavailable(err)).To(BeTrue(), "the example stream ends, which should be reported as unavailable")
+		Expect(batches).To(HaveLen(1))
+		Expect(batches[0]).NotTo(BeEmpty())
+	})
+
+	DescribeTable("should rely only on documented error statuses",
+		func(method, path string, status int) {
+			operation := findOperation(doc, method, path)
+			Expect(operation).NotTo(BeNil(), "%s %s is not documented", method, path)
+			Expect(operation.Responses.StatusCodeResponses).To(HaveKey(status))
+		},
+		Entry("shorten conflict", http.MethodPost, "/shorten", http.StatusConflict),
+		Entry("shorten invalid", http.MethodPost, "/shorten", http.StatusUnprocessableEntity),
+		Entry("get not found", http.MethodGet, "/links/a", http.StatusNotFound),
+		Entry("update not found", http.MethodPut, "/links/a", http.StatusNotFound),
+		Entry("delete not found", http.MethodDelete, "/links/a", http.StatusNotFound),
+		Entry("stats not found", http.MethodGet, "/count/a", http.StatusNotFound),
+		Entry("validity not found", http.MethodGet, "/valid/a", http.StatusNotFound),
+		Entry("shorten unauthorized", http.MethodPost, "/shorten", http.StatusUnauthorized),
+		Entry("update unauthorized", http.MethodPut, "/links/a", http.StatusUnauthorized),
+		Entry("stats unauthorized", http.MethodGet, "/count/a", http.StatusUnauthorized),
+		Entry("events unauthorized", http.MethodGet, "/events", http.StatusUnauthorized),
+	)
+})
+
+// serveExample checks a request against the operation documented for it and
+// answers with the example of its success response.
+func serveExample(doc *spec3.OpenAPI, w http.ResponseWriter, r *http.Request) {
+	operation := findOperation(doc, r.Method, r.URL.Path)
+	Expect(operation).NotTo(BeNil(), "%s %s is not documented", r.Method, r.URL.Path)
+	expectAuthorized(doc, operation, r)
+
+	if operation.RequestBody != nil {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		Expect(err).NotTo(HaveOccurred())
+		content := operation.RequestBody.Content[mediaType]
+		Expect(content).NotTo(BeNil(), "%s %s: %s bodies are not documented", r.Method, r.URL.Path, mediaType)
+
+		var body any
+		Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+		expectValid(doc, content.Schema, body, "request body")
+	}
+
+	status, response := successResponse(doc, operation)
+	Expect(response).NotTo(BeNil(), "%s %s has no success response", r.Method, r.URL.Path)
+	if len(response.Content) == 0 {
+		w.WriteHeader(status)
+		return
+	}
+	for mediaType, content := range response.Content {
+		Expect(content.Example).NotTo(BeNil(), "%s %s: %d response has no example", r.Method, r.URL.Path, status)
+		w.Header().Set("Content-Type", mediaType)
+		w.WriteHeader(status)
+		if mediaType == "text/event-stream" {
+			stream := content.Example.(string)
+			expectValidEvents(doc, stream)
+			fmt.Fprint(w, stream)
+			return
+		}
+		expectValid(doc, content.Schema, content.Example, "response example")
+		Expect(json.NewEncoder(w).Encode(content.Example)).To(Succeed())
+		return
+	}
+}
+
+// expectAuthorized checks that r meets one of the security requirements of
+// operation, which default to those of the document.
+func expectAuthorized(doc *spec3.OpenAPI, operation *spec3.Operation, r *http.Request) {
+	requirements := operation.SecurityRequirement
+	if requirements == nil {
+		requirements = doc.SecurityRequirement
+	}
+	if len(requirements) == 0 {
+		return
+	}
+
+	for _, requirement := range requirements {
+		met := true
+		for name := range requirement {
+			scheme := doc.Components.SecuritySchemes[name]
+			Expect(scheme).NotTo(BeNil(), "security scheme %s is not documented", name)
+			switch {
+			case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"):
+				met = met && r.Header.Get("Authorization") == "Bearer "+contractAPIKey
+			case scheme.Type == "apiKey" && scheme.In == "header":
+				met = met && r.Header.Get(scheme.Name) == contractAPIKey
+			default:
+				met = false
+			}
+		}
+		if met {
+			return
+		}
+	}
+	Fail(fmt.Sprintf("%s %s does not meet any security requirement", r.Method, r.URL.Path))
+}
+
+// findOperation returns the operation documented for a request, preferring
+// paths with more literal segments, e.g. /shorten over /{path}.
+func findOperation(doc *spec3.OpenAPI, method, path string) *spec3.Operation {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	var templates []string
+	for template := range doc.Paths.Paths {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.Count(templates[i], "{") < strings.Count(templates[j], "{")
+	})
+
+	for _, template := range templates {
+		parts := strings.Split(strings.TrimPrefix(template, "/"), "/")
+		if len(parts) != len(segments) {
+			continue
+		}
+		matches := true
+		for i, part := range parts {
+			if !strings.HasPrefix(part, "{") && part != segments[i] {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		item := doc.Paths.Paths[template]
+		switch method {
+		case http.MethodGet:
+			return item.Get
+		case http.MethodPost:
+			return item.Post
+		case http.MethodPut:
+			return item.Put
+		case http.MethodPatch:
+			return item.Patch
+		case http.MethodDelete:
+			return item.Delete
+		}
+		return nil
+	}
+	return nil
+}
+
+// successResponse returns the lowest documented 2xx response of operation.
+func successResponse(doc *spec3.OpenAPI, operation *spec3.Operation) (int, *spec3.Response) {
+	for status := 200; status < 300; status++ {
+		if response, ok := operation.Responses.StatusCodeResponses[status]; ok {
+			if ref := response.Ref.String(); ref != "" {
+				response = doc.Components.Responses[strings.TrimPrefix(ref, "#/components/responses/")]
+			}
+			return status, response
+		}
+	}
+	return 0, nil
+}
+
+// expectValidEvents checks the data of the clicks events in an event stream
+// against the ClickEvent schema.
+func expectValidEvents(doc *spec3.OpenAPI, stream string) {
+	events := &spec.Schema{SchemaProps: spec.SchemaProps{
+		Type:  spec.StringOrArray{"array"},
+		Items: &spec.SchemaOrArray{Schema: spec.RefSchema("#/components/schemas/ClickEvent")},
+	}}
+	for _, line := range strings.Split(stream, "\n") {
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var batch any
+			Expect(json.Unmarshal([]byte(data), &batch)).To(Succeed())
+			expectValid(doc, events, batch, "click event")
+		}
+	}
+}
+
+func expectValid(doc *spec3.OpenAPI, schema *spec.Schema, data any, what string) {
+	result := validate.NewSchemaValidator(resolveRefs(doc, schema), nil, "", strfmt.Default).Validate(data)
+	Expect(result.AsError()).NotTo(HaveOccurred(), "%s does not match the OpenAPI document: %v", what, data)
+}
+
+// resolveRefs returns a copy of schema with references to the component
+// schemas of doc inlined, since the validator does not follow them.
+func resolveRefs(doc *spec3.OpenAPI, schema *spec.Schema) *spec.Schema {
+	if schema == nil {
+		return nil
+	}
+	if ref := schema.Ref.String(); ref != "" {
+		target, ok := doc.Components.Schemas[strings.TrimPrefix(ref, "#/components/schemas/")]
+		Expect(ok).To(BeTrue(), "unknown schema %s", ref)
+		return resolveRefs(doc, target)
+	}
+
+	resolved := *schema
+	if schema.Properties != nil {
+		resolved.Properties = map[string]spec.Schema{}
+		for name, property := range schema.Properties {
+			resolved.Properties[name] = *resolveRefs(doc, &property)
+		}
+	}
+	if schema.Items != nil && schema.Items.Schema != nil {
+		resolved.Items = &spec.SchemaOrArray{Schema: resolveRefs(doc, schema.Items.Schema)}
+	}
+	if schema.AllOf != nil {
+		resolved.AllOf = make([]spec.Schema, len(schema.AllOf))
+		for i := range schema.AllOf {
+			resolved.AllOf[i] = *resolveRefs(doc, &schema.AllOf[i])
+		}
+	}
+	return &resolved
+}
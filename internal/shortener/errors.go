@@ -0,0 +1,87 @@
+package shortener
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrNotFound means the shortener does not know the short path, e.g.
+	// because it lost its data on restart.
+	ErrNotFound = errors.New("short URL not found")
+	// ErrConflict means the requested short path is taken by another link.
+	ErrConflict = errors.New("short URL already exists")
+	// ErrInvalid means the shortener refused the request as invalid, e.g.
+	// because of a malformed target URL or an unsupported redirect type.
+	ErrInvalid = errors.New("request rejected by shortener")
+	// ErrInvalidShortURL means the shortener refused the requested short
+	// path, e.g. because it is a reserved word. It is also an ErrInvalid.
+	ErrInvalidShortURL = errors.New("short URL rejected by shortener")
+	// ErrUnauthorized means the shortener did not accept the API key of the
+	// client, or the client sent none.
+	ErrUnauthorized = errors.New("API key rejected by shortener")
+	// ErrUnavailable means the shortener could not be reached or failed to
+	// answer. Requests failing with it may succeed when retried.
+	ErrUnavailable = errors.New("shortener unavailable")
+)
+
+// Error is a failed request to the shortener. Use errors.Is with ErrNotFound,
+// ErrConflict, ErrInvalid, ErrInvalidShortURL, ErrUnauthorized and
+// ErrUnavailable to tell failures apart.
+type Error struct {
+	Method string
+	Path   string
+	// StatusCode is the HTTP status of the response, or 0 if none was
+	// received. For gRPC calls, Method is "gRPC", Path the full method name
+	// and StatusCode the HTTP equivalent of the gRPC status. The shortener
+	// answers 422 Unprocessable Entity only for a rejected short path.
+	StatusCode int
+	// Message is the error text the shortener answered with.
+	Message string
+	// Err is the transport error when no response was received.
+	Err error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s: %v", e.Method, e.Path, e.Err)
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, http.StatusText(e.StatusCode), e.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", e.Method, e.Path, http.StatusText(e.StatusCode))
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is maps the response status to the sentinel errors of this package.
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrInvalid:
+		return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusUnprocessableEntity
+	case ErrInvalidShortURL:
+		return e.StatusCode == http.StatusUnprocessableEntity
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrUnavailable:
+		return e.Err != nil || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+	}
+	return false
+}
+
+// IsNotFound reports whether err means the short path does not exist.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsUnavailable reports whether err is a transient failure of the shortener.
+func IsUnavailable(err error) bool {
+	return errors.Is(err, ErrUnavailable)
+}
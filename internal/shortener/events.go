@@ -0,0 +1,101 @@
+package shortener
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// clickEventType is the server-sent event type of a batch of ClickEvents.
+const clickEventType = "clicks"
+
+// WatchClicks reads the /events stream of the shortener. The stream is not
+// bound by the request timeout of the client, and it is not retried: callers
+// are expected to reconnect, and to poll for what they missed meanwhile.
+func (c *HTTPClient) WatchClicks(ctx context.Context, fn func([]ClickEvent)) error {
+	const path = "/events"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	c.authorize(req)
+
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return &Error{Method: http.MethodGet, Path: path, Err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &Error{
+			Method:     http.MethodGet,
+			Path:       path,
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(message)),
+		}
+	}
+
+	var decodeErr error
+	err = readEvents(resp.Body, func(eventType, data string) error {
+		if eventType != clickEventType {
+			return nil
+		}
+		var batch []ClickEvent
+		if err := json.Unmarshal([]byte(data), &batch); err != nil {
+			decodeErr = fmt.Errorf("GET %s: decoding event: %w", path, err)
+			return decodeErr
+		}
+		fn(batch)
+		return nil
+	})
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case decodeErr != nil:
+		return decodeErr
+	case err == nil:
+		// The shortener ended the stream, e.g. because it is shutting down.
+		err = io.ErrUnexpectedEOF
+	}
+	return &Error{Method: http.MethodGet, Path: path, Err: err}
+}
+
+// readEvents parses a server-sent event stream and calls fn for each event.
+// Comments and fields other than event and data are ignored.
+func readEvents(r io.Reader, fn func(eventType, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var eventType string
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if err := fn(eventType, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			eventType, data = "", nil
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			eventType = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
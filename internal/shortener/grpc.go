@@ -0,0 +1,357 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"urlshortener-operator/internal/shortener/shortenerpb"
+)
+
+// GRPCClient is the Client for the urlshortener-app gRPC API. It keeps a
+// connection open until it is closed.
+type GRPCClient struct {
+	conn         *grpc.ClientConn
+	api          shortenerpb.ShortenerClient
+	apiKey       string
+	timeout      time.Duration
+	maxRetries   int
+	retryBackoff time.Duration
+}
+
+var _ Client = &GRPCClient{}
+
+// NewGRPC returns a client for the gRPC API at target, e.g.
+// "shortener.default.svc.cluster.local:50051". It connects lazily, on the
+// first request. opts.HTTPClient is ignored.
+func NewGRPC(target string, opts Options) (*GRPCClient, error) {
+	conn, err := grpc.NewClient(target, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, err
+	}
+	c := &GRPCClient{
+		conn:         conn,
+		api:          shortenerpb.NewShortenerClient(conn),
+		apiKey:       opts.APIKey,
+		timeout:      opts.Timeout,
+		maxRetries:   opts.MaxRetries,
+		retryBackoff: opts.RetryBackoff,
+	}
+	if c.timeout == 0 {
+		c.timeout = 10 * time.Second
+	}
+	if c.maxRetries == 0 {
+		c.maxRetries = 3
+	}
+	if c.retryBackoff == 0 {
+		c.retryBackoff = 200 * time.Millisecond
+	}
+	return c, nil
+}
+
+// GRPCFactory creates gRPC clients for shorteners that serve a gRPC API, and
+// HTTP clients for the others. Clients of the same address share one
+// connection, also when the API key of the shortener changes, until
+// CloseUnused is told the address is no longer in use.
+type GRPCFactory struct {
+	opts    Options
+	mu      sync.Mutex
+	clients map[string]*GRPCClient
+}
+
+// NewGRPCFactory returns a GRPCFactory creating clients with opts.
+func NewGRPCFactory(opts Options) *GRPCFactory {
+	return &GRPCFactory{opts: opts, clients: map[string]*GRPCClient{}}
+}
+
+// Client returns a client for the shortener at endpoint. It is a Factory.
+func (f *GRPCFactory) Client(endpoint Endpoint) Client {
+	opts := f.opts.forEndpoint(endpoint)
+	if endpoint.GRPCAddress == "" {
+		return New(endpoint.URL, opts)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[endpoint.GRPCAddress]; ok {
+		if c.apiKey != endpoint.APIKey {
+			c = c.withAPIKey(endpoint.APIKey)
+			f.clients[endpoint.GRPCAddress] = c
+		}
+		return c
+	}
+	c, err := NewGRPC(endpoint.GRPCAddress, opts)
+	if err != nil {
+		// Only a malformed address fails here, which the HTTP API of the
+		// same shortener does not depend on.
+		return New(endpoint.URL, opts)
+	}
+	f.clients[endpoint.GRPCAddress] = c
+	return c
+}
+
+// CloseUnused closes the connections to all addresses but inUse, e.g. those
+// of deleted shorteners. Calls still running on them fail, and the next
+// client for such an address opens a new connection.
+func (f *GRPCFactory) CloseUnused(inUse []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for address, c := range f.clients {
+		if !slices.Contains(inUse, address) {
+			c.Close()
+			delete(f.clients, address)
+		}
+	}
+}
+
+// withAPIKey returns a client sharing the connection of c that authenticates
+// with apiKey.
+func (c *GRPCClient) withAPIKey(apiKey string) *GRPCClient {
+	clone := *c
+	clone.apiKey = apiKey
+	return &clone
+}
+
+// Close closes the connection of the client.
+func (c *GRPCClient) Close() error {
+	return c.conn.Close()
+}
+
+// Shorten is not retried, for the same reason as HTTPClient.Shorten.
+func (c *GRPCClient) Shorten(ctx context.Context, req ShortenRequest) (*Link, error) {
+	var link *shortenerpb.Link
+	err := c.call(ctx, false, shortenerpb.Shortener_Shorten_FullMethodName, func(ctx context.Context) error {
+		var err error
+		link, err = c.api.Shorten(ctx, &shortenerpb.ShortenRequest{
+			LongUrl:      req.LongURL,
+			ExpireAt:     timestampFrom(req.ExpireAt),
+			NotBefore:    timestampFrom(req.NotBefore),
+			MaxClicks:    int32(req.MaxClicks),
+			RedirectType: int32(req.RedirectType),
+			ShortUrl:     req.ShortURL,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return linkFromProto(link), nil
+}
+
+func (c *GRPCClient) Get(ctx context.Context, shortURL string) (*Link, error) {
+	resp, err := c.resolve(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	return linkFromProto(resp.GetLink()), nil
+}
+
+func (c *GRPCClient) Update(ctx context.Context, shortURL string, req UpdateRequest) error {
+	return c.call(ctx, true, shortenerpb.Shortener_Update_FullMethodName, func(ctx context.Context) error {
+		_, err := c.api.Update(ctx, &shortenerpb.UpdateRequest{
+			ShortUrl:     shortURL,
+			LongUrl:      req.LongURL,
+			ExpireAt:     timestampFrom(req.ExpireAt),
+			NotBefore:    timestampFrom(req.NotBefore),
+			MaxClicks:    int32(req.MaxClicks),
+			RedirectType: int32(req.RedirectType),
+		})
+		return err
+	})
+}
+
+func (c *GRPCClient) Delete(ctx context.Context, shortURL string) error {
+	return c.call(ctx, true, shortenerpb.Shortener_Delete_FullMethodName, func(ctx context.Context) error {
+		_, err := c.api.Delete(ctx, &shortenerpb.DeleteRequest{ShortUrl: shortURL})
+		return err
+	})
+}
+
+func (c *GRPCClient) Stats(ctx context.Context, shortURL string) (*Stats, error) {
+	var stats *shortenerpb.Stats
+	err := c.call(ctx, true, shortenerpb.Shortener_GetStats_FullMethodName, func(ctx context.Context) error {
+		var err error
+		stats, err = c.api.GetStats(ctx, &shortenerpb.GetStatsRequest{ShortUrl: shortURL})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Stats{ClickCount: int(stats.GetClickCount())}, nil
+}
+
+func (c *GRPCClient) Validity(ctx context.Context, shortURL string) (*Validity, error) {
+	resp, err := c.resolve(ctx, shortURL)
+	if err != nil {
+		return nil, err
+	}
+	validity := &Validity{IsValid: resp.GetState().GetIsValid()}
+	if resp.GetState().RemainingClicks != nil {
+		remaining := int(resp.GetState().GetRemainingClicks())
+		validity.RemainingClicks = &remaining
+	}
+	return validity, nil
+}
+
+// WatchClicks follows the WatchClicks stream of the shortener. Like
+// HTTPClient.WatchClicks, the stream is neither bound by the request timeout
+// nor retried.
+func (c *GRPCClient) WatchClicks(ctx context.Context, fn func([]ClickEvent)) error {
+	const method = shortenerpb.Shortener_WatchClicks_FullMethodName
+	stream, err := c.api.WatchClicks(c.authorize(ctx), &shortenerpb.WatchClicksRequest{})
+	if err != nil {
+		return grpcError(method, err)
+	}
+	for {
+		batch, err := stream.Recv()
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case errors.Is(err, io.EOF):
+			// The shortener ended the stream, e.g. because it is shutting down.
+			return &Error{Method: "gRPC", Path: method, Err: io.ErrUnexpectedEOF}
+		case err != nil:
+			return grpcError(method, err)
+		}
+
+		events := make([]ClickEvent, 0, len(batch.GetEvents()))
+		for _, event := range batch.GetEvents() {
+			events = append(events, ClickEvent{
+				ShortURL:   event.GetShortUrl(),
+				Clicks:     int(event.GetClicks()),
+				ClickCount: int(event.GetClickCount()),
+			})
+		}
+		fn(events)
+	}
+}
+
+func (c *GRPCClient) resolve(ctx context.Context, shortURL string) (*shortenerpb.ResolveResponse, error) {
+	var resp *shortenerpb.ResolveResponse
+	err := c.call(ctx, true, shortenerpb.Shortener_Resolve_FullMethodName, func(ctx context.Context) error {
+		var err error
+		resp, err = c.api.Resolve(ctx, &shortenerpb.ResolveRequest{ShortUrl: shortURL})
+		return err
+	})
+	return resp, err
+}
+
+// call runs one attempt of a unary call per invocation of fn, bounding each by
+// the timeout of the client, and maps failures to *Error. Calls failing with
+// ErrUnavailable are retried if retry is set.
+func (c *GRPCClient) call(ctx context.Context, retry bool, method string, fn func(ctx context.Context) error) error {
+	maxRetries := c.maxRetries
+	if !retry {
+		maxRetries = 0
+	}
+	return withRetries(ctx, maxRetries, c.retryBackoff, func() error {
+		attemptCtx, cancel := context.WithTimeout(c.authorize(ctx), c.timeout)
+		defer cancel()
+		if err := fn(attemptCtx); err != nil {
+			return grpcError(method, err)
+		}
+		return nil
+	})
+}
+
+// authorize adds the API key of the client to the metadata of the calls made
+// with ctx.
+func (c *GRPCClient) authorize(ctx context.Context) context.Context {
+	if c.apiKey == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.apiKey)
+}
+
+// grpcError converts the status of a failed call to an *Error with the
+// equivalent HTTP status, so that both APIs fail with the same errors.
+func grpcError(method string, err error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return &Error{Method: "gRPC", Path: method, Err: err}
+	}
+	statusCode := http.StatusInternalServerError
+	switch st.Code() {
+	case codes.Canceled:
+		return &Error{Method: "gRPC", Path: method, Err: err}
+	case codes.NotFound:
+		statusCode = http.StatusNotFound
+	case codes.AlreadyExists:
+		statusCode = http.StatusConflict
+	case codes.InvalidArgument:
+		statusCode = http.StatusBadRequest
+		if violatesField(st, "short_url") {
+			statusCode = http.StatusUnprocessableEntity
+		}
+	case codes.FailedPrecondition, codes.OutOfRange:
+		statusCode = http.StatusBadRequest
+	case codes.Unauthenticated:
+		statusCode = http.StatusUnauthorized
+	case codes.PermissionDenied:
+		statusCode = http.StatusForbidden
+	case codes.ResourceExhausted:
+		statusCode = http.StatusTooManyRequests
+	case codes.Unimplemented:
+		statusCode = http.StatusNotImplemented
+	case codes.Unavailable:
+		statusCode = http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		statusCode = http.StatusGatewayTimeout
+	}
+	return &Error{Method: "gRPC", Path: method, StatusCode: statusCode, Message: st.Message()}
+}
+
+func timestampFrom(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func timeFrom(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
+
+func linkFromProto(link *shortenerpb.Link) *Link {
+	return &Link{
+		ShortURL:     link.GetShortUrl(),
+		LongURL:      link.GetLongUrl(),
+		ExpireAt:     timeFrom(link.GetExpireAt()),
+		NotBefore:    timeFrom(link.GetNotBefore()),
+		MaxClicks:    int(link.GetMaxClicks()),
+		RedirectType: int(link.GetRedirectType()),
+	}
+}
+
+// violatesField reports whether st blames the request field name, like the
+// 422 answer of the HTTP API for a rejected short path.
+func violatesField(st *status.Status, name string) bool {
+	for _, detail := range st.Details() {
+		badRequest, ok := detail.(*errdetails.BadRequest)
+		if !ok {
+			continue
+		}
+		for _, violation := range badRequest.GetFieldViolations() {
+			if violation.GetField() == name {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package shortener
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"urlshortener-operator/internal/shortener/shortenerpb"
+)
+
+// fakeShortener serves one link, "abc", and fails every call with err when
+// it is set.
+type fakeShortener struct {
+	shortenerpb.UnimplementedShortenerServer
+	err   error
+	calls atomic.Int32
+	// shortened is the last request to Shorten.
+	shortened *shortenerpb.ShortenRequest
+	// authorization is the authorization metadata of the last call to
+	// GetStats.
+	authorization []string
+}
+
+func (f *fakeShortener) fail() error {
+	f.calls.Add(1)
+	return f.err
+}
+
+func (f *fakeShortener) Shorten(ctx context.Context, req *shortenerpb.ShortenRequest) (*shortenerpb.Link, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	f.shortened = req
+	return &shortenerpb.Link{ShortUrl: "abc", LongUrl: req.GetLongUrl(), ExpireAt: req.GetExpireAt()}, nil
+}
+
+func (f *fakeShortener) Resolve(ctx context.Context, req *shortenerpb.ResolveRequest) (*shortenerpb.ResolveResponse, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	if req.GetShortUrl() != "abc" {
+		return nil, status.Error(codes.NotFound, "short URL not found")
+	}
+	remaining := int32(2)
+	return &shortenerpb.ResolveResponse{
+		Link:       &shortenerpb.Link{ShortUrl: "abc", LongUrl: "https://example.com", MaxClicks: 3, RedirectType: 301},
+		State:      &shortenerpb.LinkState{IsValid: true, IsActive: true, RemainingClicks: &remaining},
+		ClickCount: 1,
+	}, nil
+}
+
+func (f *fakeShortener) GetStats(ctx context.Context, req *shortenerpb.GetStatsRequest) (*shortenerpb.Stats, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.authorization = md.Get("authorization")
+	return &shortenerpb.Stats{ClickCount: 7}, nil
+}
+
+func (f *fakeShortener) Delete(ctx context.Context, req *shortenerpb.DeleteRequest) (*shortenerpb.DeleteResponse, error) {
+	if err := f.fail(); err != nil {
+		return nil, err
+	}
+	return &shortenerpb.DeleteResponse{}, nil
+}
+
+func (f *fakeShortener) WatchClicks(req *shortenerpb.WatchClicksRequest, stream grpc.ServerStreamingServer[shortenerpb.ClickBatch]) error {
+	return stream.Send(&shortenerpb.ClickBatch{Events: []*shortenerpb.ClickEvent{
+		{ShortUrl: "a", Clicks: 2, ClickCount: 5},
+	}})
+}
+
+var _ = Describe("GRPCClient", func() {
+	var (
+		ctx    context.Context
+		fake   *fakeShortener
+		client *GRPCClient
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		fake = &fakeShortener{}
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		server := grpc.NewServer()
+		shortenerpb.RegisterShortenerServer(server, fake)
+		go server.Serve(listener)
+		DeferCleanup(server.Stop)
+
+		client, err = NewGRPC(listener.Addr().String(), Options{RetryBackoff: time.Millisecond})
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(client.Close)
+	})
+
+	It("should register links", func() {
+		expireAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		link, err := client.Shorten(ctx, ShortenRequest{LongURL: "https://example.com", ExpireAt: &expireAt, MaxClicks: 3})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link.ShortURL).To(Equal("abc"))
+		Expect(*link.ExpireAt).To(BeTemporally("==", expireAt))
+		Expect(fake.shortened.GetMaxClicks()).To(Equal(int32(3)))
+	})
+
+	It("should decode links, stats and validity", func() {
+		link, err := client.Get(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(link).To(Equal(&Link{ShortURL: "abc", LongURL: "https://example.com", MaxClicks: 3, RedirectType: 301}))
+
+		stats, err := client.Stats(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(stats.ClickCount).To(Equal(7))
+
+		validity, err := client.Validity(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(validity.IsValid).To(BeTrue())
+		Expect(validity.RemainingClicks).To(HaveValue(Equal(2)))
+	})
+
+	DescribeTable("should map status codes to typed errors",
+		func(code codes.Code, target error) {
+			fake.err = status.Error(code, "nope")
+
+			err := client.Delete(ctx, "abc")
+			Expect(errors.Is(err, target)).To(BeTrue(), "got %v", err)
+
+			var clientErr *Error
+			Expect(errors.As(err, &clientErr)).To(BeTrue())
+			Expect(clientErr.Message).To(Equal("nope"))
+		},
+		Entry("not found", codes.NotFound, ErrNotFound),
+		Entry("already exists", codes.AlreadyExists, ErrConflict),
+		Entry("invalid argument", codes.InvalidArgument, ErrInvalid),
+		Entry("failed precondition", codes.FailedPrecondition, ErrInvalid),
+		Entry("unauthenticated", codes.Unauthenticated, ErrUnauthorized),
+		Entry("internal", codes.Internal, ErrUnavailable),
+		Entry("unavailable", codes.Unavailable, ErrUnavailable),
+	)
+
+	DescribeTable("should only blame the short URL for its field violations",
+		func(field string, invalidShortURL bool) {
+			st, err := status.New(codes.InvalidArgument, "invalid "+field).WithDetails(&errdetails.BadRequest{
+				FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: field, Description: "nope"}},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			fake.err = st.Err()
+
+			_, err = client.Shorten(ctx, ShortenRequest{LongURL: "https://example.com", ShortURL: "shorten"})
+			Expect(errors.Is(err, ErrInvalid)).To(BeTrue(), "got %v", err)
+			Expect(errors.Is(err, ErrInvalidShortURL)).To(Equal(invalidShortURL), "got %v", err)
+		},
+		Entry("short URL", "short_url", true),
+		Entry("long URL", "long_url", false),
+	)
+
+	It("should send its API key", func() {
+		_, err := client.Stats(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.authorization).To(BeEmpty())
+
+		_, err = client.withAPIKey("secret").Stats(ctx, "abc")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fake.authorization).To(Equal([]string{"Bearer secret"}))
+	})
+
+	It("should retry idempotent calls but not registering a link", func() {
+		fake.err = status.Error(codes.Unavailable, "starting up")
+
+		_, err := client.Stats(ctx, "abc")
+		Expect(IsUnavailable(err)).To(BeTrue())
+		Expect(fake.calls.Load()).To(Equal(int32(4)))
+
+		fake.calls.Store(0)
+		_, err = client.Shorten(ctx, ShortenRequest{LongURL: "https://example.com"})
+		Expect(IsUnavailable(err)).To(BeTrue())
+		Expect(fake.calls.Load()).To(Equal(int32(1)))
+	})
+
+	It("should stream click events", func() {
+		var batches [][]ClickEvent
+		err := client.WatchClicks(ctx, func(batch []ClickEvent) {
+			batches = append(batches, batch)
+		})
+		Expect(IsUnavailable(err)).To(BeTrue(), "a closed stream should be reported as unavailable")
+		Expect(batches).To(Equal([][]ClickEvent{{{ShortURL: "a", Clicks: 2, ClickCount: 5}}}))
+	})
+})
+
+var _ = Describe("GRPCFactory", func() {
+	var factory *GRPCFactory
+
+	BeforeEach(func() {
+		factory = NewGRPCFactory(Options{})
+		DeferCleanup(factory.CloseUnused, []string(nil))
+	})
+
+	It("should share connections per address and fall back to HTTP", func() {
+		Expect(factory.Client(Endpoint{URL: "http://shortener:8080"})).To(BeAssignableToTypeOf(&HTTPClient{}))
+
+		endpoint := Endpoint{URL: "http://shortener:8080", GRPCAddress: "shortener:50051"}
+		client := factory.Client(endpoint)
+		Expect(client).To(BeAssignableToTypeOf(&GRPCClient{}))
+		Expect(factory.Client(endpoint)).To(BeIdenticalTo(client))
+
+		endpoint.APIKey = "secret"
+		withKey := factory.Client(endpoint).(*GRPCClient)
+		Expect(withKey.apiKey).To(Equal("secret"))
+		Expect(withKey.conn).To(BeIdenticalTo(client.(*GRPCClient).conn))
+	})
+
+	It("should close the connections to addresses no longer in use", func() {
+		kept := factory.Client(Endpoint{GRPCAddress: "kept:50051"}).(*GRPCClient)
+		gone := factory.Client(Endpoint{GRPCAddress: "gone:50051"}).(*GRPCClient)
+
+		factory.CloseUnused([]string{"kept:50051"})
+
+		Expect(gone.conn.GetState()).To(Equal(connectivity.Shutdown))
+		Expect(kept.conn.GetState()).NotTo(Equal(connectivity.Shutdown))
+		Expect(factory.Client(Endpoint{GRPCAddress: "kept:50051"})).To(BeIdenticalTo(kept))
+
+		reopened := factory.Client(Endpoint{GRPCAddress: "gone:50051"}).(*GRPCClient)
+		Expect(reopened.conn).NotTo(BeIdenticalTo(gone.conn))
+		Expect(reopened.conn.GetState()).NotTo(Equal(connectivity.Shutdown))
+	})
+})
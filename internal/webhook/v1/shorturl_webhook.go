@@ -0,0 +1,280 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+// nolint:unused
+// log is for logging in this package.
+var shorturllog = logf.Log.WithName("shorturl-resource")
+
+// SetupShortURLWebhookWithManager registers the webhook for ShortURL in the manager.
+func SetupShortURLWebhookWithManager(mgr ctrl.Manager, defaulter *ShortURLCustomDefaulter,
+	validator *ShortURLCustomValidator) error {
+	if defaulter.Client == nil {
+		defaulter.Client = mgr.GetClient()
+	}
+	return ctrl.NewWebhookManagedBy(mgr).For(&urlshortenerv1.ShortURL{}).
+		WithValidator(validator).
+		WithDefaulter(defaulter).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-urlshortener-shortener-io-v1-shorturl,mutating=true,failurePolicy=fail,sideEffects=None,groups=urlshortener.shortener.io,resources=shorturls,verbs=create;update,versions=v1,name=mshorturl-v1.kb.io,admissionReviewVersions=v1
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// ShortURLCustomDefaulter struct is responsible for setting default values on the custom resource of the
+// Kind ShortURL when those are created or updated.
+type ShortURLCustomDefaulter struct {
+	// Client reads the namespace of a ShortURL to look up its default TTL.
+	Client client.Reader
+	// RedirectType is stamped onto ShortURLs that do not choose one.
+	RedirectType int32
+	// URLScheme is prepended to target URLs that are missing a scheme.
+	URLScheme string
+	// TTL is the expiry given to new ShortURLs in namespaces without the
+	// default TTL annotation. Zero means such links never expire.
+	TTL time.Duration
+}
+
+var _ webhook.CustomDefaulter = &ShortURLCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind ShortURL.
+func (d *ShortURLCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	shorturl, ok := obj.(*urlshortenerv1.ShortURL)
+	if !ok {
+		return fmt.Errorf("expected an ShortURL object but got %T", obj)
+	}
+	shorturllog.Info("Defaulting for ShortURL", "name", shorturl.GetName())
+
+	shorturl.Spec.TargetURL = d.normalizeTargetURL(shorturl.Spec.TargetURL)
+	if shorturl.Spec.RedirectType == 0 {
+		shorturl.Spec.RedirectType = d.RedirectType
+	}
+
+	// The TTL only applies to new links; clearing expireAt later must not
+	// bring it back.
+	if req, err := admission.RequestFromContext(ctx); err == nil && req.Operation != admissionv1.Create {
+		return nil
+	}
+	if shorturl.Spec.ExpireAt == nil {
+		ttl, err := d.namespaceTTL(ctx, shorturl.Namespace)
+		if err != nil {
+			return err
+		}
+		if ttl > 0 {
+			shorturl.Spec.ExpireAt = &metav1.Time{Time: time.Now().Add(ttl).Truncate(time.Second)}
+		}
+	}
+
+	return nil
+}
+
+// normalizeTargetURL adds the default scheme to a target URL that has none
+// and lowercases its scheme and host. Values that cannot be parsed are left
+// for the validator to reject.
+func (d *ShortURLCustomDefaulter) normalizeTargetURL(target string) string {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return target
+	}
+	if !strings.Contains(target, "://") && d.URLScheme != "" {
+		target = d.URLScheme + "://" + target
+	}
+
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Host == "" {
+		return target
+	}
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	parsed.Host = strings.ToLower(parsed.Host)
+	return parsed.String()
+}
+
+// namespaceTTL returns the default TTL annotated on the namespace, falling
+// back to d.TTL.
+func (d *ShortURLCustomDefaulter) namespaceTTL(ctx context.Context, namespace string) (time.Duration, error) {
+	if d.Client == nil || namespace == "" {
+		return d.TTL, nil
+	}
+
+	ns := &corev1.Namespace{}
+	if err := d.Client.Get(ctx, types.NamespacedName{Name: namespace}, ns); err != nil {
+		return 0, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	value, ok := ns.Annotations[urlshortenerv1.DefaultTTLAnnotation]
+	if !ok {
+		return d.TTL, nil
+	}
+
+	ttl, err := urlshortenerv1.ParseTTL(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s annotation on namespace %s: %w",
+			urlshortenerv1.DefaultTTLAnnotation, namespace, err)
+	}
+	return ttl, nil
+}
+
+// +kubebuilder:webhook:path=/validate-urlshortener-shortener-io-v1-shorturl,mutating=false,failurePolicy=fail,sideEffects=None,groups=urlshortener.shortener.io,resources=shorturls,verbs=create;update,versions=v1,name=vshorturl-v1.kb.io,admissionReviewVersions=v1
+
+// ShortURLCustomValidator struct is responsible for validating the ShortURL resource
+// when it is created, updated, or deleted.
+type ShortURLCustomValidator struct {
+	// AllowedSchemes lists the URL schemes a target URL may use.
+	AllowedSchemes []string
+	// AllowedDomains, when not empty, restricts target URLs to these domains
+	// and their subdomains.
+	AllowedDomains []string
+	// DeniedDomains rejects target URLs on these domains and their
+	// subdomains. It takes precedence over AllowedDomains.
+	DeniedDomains []string
+}
+
+var _ webhook.CustomValidator = &ShortURLCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type ShortURL.
+func (v *ShortURLCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	shorturl, ok := obj.(*urlshortenerv1.ShortURL)
+	if !ok {
+		return nil, fmt.Errorf("expected a ShortURL object but got %T", obj)
+	}
+	shorturllog.Info("Validation for ShortURL upon creation", "name", shorturl.GetName())
+
+	allErrs := v.validateTargetURL(shorturl)
+
+	if expireAt := shorturl.Spec.ExpireAt; expireAt != nil && !expireAt.Time.After(time.Now()) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "expireAt"),
+			expireAt.Time.Format(time.RFC3339), "must be in the future"))
+	}
+
+	return nil, invalid(shorturl, allErrs)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type ShortURL.
+func (v *ShortURLCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	shorturl, ok := newObj.(*urlshortenerv1.ShortURL)
+	if !ok {
+		return nil, fmt.Errorf("expected a ShortURL object for the newObj but got %T", newObj)
+	}
+	oldShorturl, ok := oldObj.(*urlshortenerv1.ShortURL)
+	if !ok {
+		return nil, fmt.Errorf("expected a ShortURL object for the oldObj but got %T", oldObj)
+	}
+	shorturllog.Info("Validation for ShortURL upon update", "name", shorturl.GetName())
+
+	// The target is only checked when it changes, so that tightening the
+	// allowed targets does not block the finalizer updates of existing links
+	// and, with them, their deletion.
+	var allErrs field.ErrorList
+	if shorturl.DeletionTimestamp.IsZero() && shorturl.Spec.TargetURL != oldShorturl.Spec.TargetURL {
+		allErrs = v.validateTargetURL(shorturl)
+	}
+
+	// The alias is the public short path; once it is assigned, changing it
+	// would silently break every link already handed out. An alias the
+	// shortener rejected was never assigned and may be fixed.
+	if oldShorturl.Status.ShortPath != "" && shorturl.Spec.Alias != oldShorturl.Spec.Alias {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "alias"),
+			"cannot be changed once the short path has been assigned"))
+	}
+
+	// Links live in the storage of their shortener, so moving a registered
+	// link to another one would leave it behind.
+	if oldShorturl.Status.ShortPath != "" &&
+		!equality.Semantic.DeepEqual(shorturl.Spec.ServiceRef, oldShorturl.Spec.ServiceRef) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", "serviceRef"),
+			"cannot be changed once the link has been registered"))
+	}
+
+	return nil, invalid(shorturl, allErrs)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type ShortURL.
+func (v *ShortURLCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateTargetURL checks the syntax, scheme and domain of spec.targetURL.
+func (v *ShortURLCustomValidator) validateTargetURL(shorturl *urlshortenerv1.ShortURL) field.ErrorList {
+	fldPath := field.NewPath("spec", "targetURL")
+	target := shorturl.Spec.TargetURL
+
+	parsed, err := url.Parse(target)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return field.ErrorList{field.Invalid(fldPath, target, "must be an absolute URL such as https://example.com")}
+	}
+
+	if !slices.Contains(v.AllowedSchemes, strings.ToLower(parsed.Scheme)) {
+		return field.ErrorList{field.NotSupported(fldPath, parsed.Scheme, v.AllowedSchemes)}
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if matchesDomain(host, v.DeniedDomains) {
+		return field.ErrorList{field.Forbidden(fldPath, fmt.Sprintf("domain %q is denied", host))}
+	}
+	if len(v.AllowedDomains) > 0 && !matchesDomain(host, v.AllowedDomains) {
+		return field.ErrorList{field.Forbidden(fldPath, fmt.Sprintf("domain %q is not in the allowed list", host))}
+	}
+
+	return nil
+}
+
+// matchesDomain reports whether host is one of domains or a subdomain of one.
+func matchesDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// invalid turns a list of field errors into an Invalid API error, or nil if
+// there are none.
+func invalid(shorturl *urlshortenerv1.ShortURL, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: urlshortenerv1.GroupVersion.Group, Kind: "ShortURL"},
+		shorturl.Name, allErrs)
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2025.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	urlshortenerv1 "urlshortener-operator/api/v1"
+)
+
+var _ = Describe("ShortURL Webhook", func() {
+	var (
+		ctx       context.Context
+		obj       *urlshortenerv1.ShortURL
+		oldObj    *urlshortenerv1.ShortURL
+		validator ShortURLCustomValidator
+		defaulter ShortURLCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		obj = &urlshortenerv1.ShortURL{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-resource", Namespace: "default"},
+			Spec:       urlshortenerv1.ShortURLSpec{TargetURL: "https://example.com/page"},
+		}
+		oldObj = obj.DeepCopy()
+		validator = ShortURLCustomValidator{
+			AllowedSchemes: []string{"http", "https"},
+			DeniedDomains:  []string{"evil.example.org"},
+		}
+		defaulter = ShortURLCustomDefaulter{
+			Client: fake.NewClientBuilder().WithObjects(
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+				&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "campaigns",
+					Annotations: map[string]string{urlshortenerv1.DefaultTTLAnnotation: "90d"},
+				}},
+			).Build(),
+			RedirectType: http.StatusFound,
+			URLScheme:    "https",
+		}
+	})
+
+	Context("When creating ShortURL under Defaulting Webhook", func() {
+		It("Should apply defaults when fields are not set", func() {
+			obj.Spec.TargetURL = "Example.COM/Some/Page"
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.TargetURL).To(Equal("https://example.com/Some/Page"))
+			Expect(obj.Spec.RedirectType).To(Equal(int32(http.StatusFound)))
+			Expect(obj.Spec.ExpireAt).To(BeNil())
+		})
+
+		It("Should keep values that are already set", func() {
+			obj.Spec.TargetURL = "HTTP://example.com"
+			obj.Spec.RedirectType = http.StatusMovedPermanently
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.TargetURL).To(Equal("http://example.com"))
+			Expect(obj.Spec.RedirectType).To(Equal(int32(http.StatusMovedPermanently)))
+		})
+
+		It("Should set expireAt from the namespace default TTL", func() {
+			obj.Namespace = "campaigns"
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.ExpireAt).NotTo(BeNil())
+			Expect(obj.Spec.ExpireAt.Time).To(BeTemporally("~", time.Now().Add(90*24*time.Hour), time.Minute))
+		})
+
+		DescribeTable("Should reject a namespace default TTL that is not positive",
+			func(ttl string) {
+				defaulter.Client = fake.NewClientBuilder().WithObjects(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+					Name:        "campaigns",
+					Annotations: map[string]string{urlshortenerv1.DefaultTTLAnnotation: ttl},
+				}}).Build()
+				obj.Namespace = "campaigns"
+				Expect(defaulter.Default(ctx, obj)).NotTo(Succeed())
+				Expect(obj.Spec.ExpireAt).To(BeNil())
+			},
+			Entry("negative days", "-5d"),
+			Entry("zero days", "0d"),
+			Entry("negative hours", "-5h"),
+			Entry("zero duration", "0s"),
+			Entry("no unit", "0"),
+		)
+
+		It("Should fall back to the operator default TTL", func() {
+			defaulter.TTL = time.Hour
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.ExpireAt).NotTo(BeNil())
+			Expect(obj.Spec.ExpireAt.Time).To(BeTemporally("~", time.Now().Add(time.Hour), time.Minute))
+		})
+
+		It("Should not set expireAt on update", func() {
+			obj.Namespace = "campaigns"
+			ctx = admission.NewContextWithRequest(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{Operation: admissionv1.Update},
+			})
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+
+			Expect(obj.Spec.ExpireAt).To(BeNil())
+		})
+	})
+
+	Context("When creating ShortURL under Validating Webhook", func() {
+		It("Should admit a valid ShortURL", func() {
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a target that is not a URL", func() {
+			obj.Spec.TargetURL = "not a url"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny a scheme that is not allowed", func() {
+			obj.Spec.TargetURL = "ftp://example.com/file"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny an expiry in the past", func() {
+			obj.Spec.ExpireAt = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny denied domains and their subdomains", func() {
+			obj.Spec.TargetURL = "https://login.evil.example.org"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should only admit allowed domains when an allow list is set", func() {
+			validator.AllowedDomains = []string{"example.com"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.TargetURL = "https://example.net"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(HaveOccurred())
+		})
+	})
+
+	Context("When updating ShortURL under Validating Webhook", func() {
+		It("Should deny changing an assigned alias", func() {
+			oldObj.Spec.Alias = "spring-sale"
+			oldObj.Status.ShortPath = "spring-sale"
+			obj.Spec.Alias = "summer-sale"
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should admit changing an alias the shortener rejected", func() {
+			oldObj.Spec.Alias = "shorten"
+			obj.Spec.Alias = "spring-sale"
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny adding an alias after a short path was generated", func() {
+			oldObj.Status.ShortPath = "aB3dE9"
+			obj.Spec.Alias = "spring-sale"
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny moving a registered link to another shortener", func() {
+			oldObj.Status.ShortPath = "aB3dE9"
+			obj.Spec.ServiceRef = &urlshortenerv1.ShortenerServiceReference{Name: "marketing"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should deny changing the target to a denied domain", func() {
+			obj.Spec.TargetURL = "https://evil.example.org"
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(HaveOccurred())
+		})
+
+		It("Should admit updates that keep a target denied since its creation", func() {
+			oldObj.Spec.TargetURL = "https://evil.example.org"
+			obj.Spec.TargetURL = oldObj.Spec.TargetURL
+			obj.Finalizers = []string{"urlshortener.shortener.io/finalizer"}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit updates of a ShortURL being deleted", func() {
+			oldObj.Spec.TargetURL = "https://evil.example.org"
+			oldObj.Finalizers = []string{"urlshortener.shortener.io/finalizer"}
+			obj.Spec.TargetURL = "ftp://evil.example.org"
+			obj.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should admit an expiry in the past on update", func() {
+			oldObj.Spec.ExpireAt = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			obj.Spec.ExpireAt = oldObj.Spec.ExpireAt
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+	})
+})
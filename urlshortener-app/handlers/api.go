@@ -0,0 +1,338 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// apiPrefix is where the versioned API is served.
+	apiPrefix = "/api/v1"
+
+	defaultPageSize = 50
+	maxPageSize     = 1000
+)
+
+// RegisterAPI serves the versioned REST API on mux:
+//
+//	POST   /api/v1/links         create a link
+//	GET    /api/v1/links         list links, ?limit=N&cursor=C
+//	GET    /api/v1/links/{path}  get a link
+//	PATCH  /api/v1/links/{path}  change some fields of a link
+//	DELETE /api/v1/links/{path}  delete a link
+//
+// Errors are answered with RFC 9457 problem details. All routes require an
+// API key if the store has any.
+func (u *URLStore) RegisterAPI(mux *http.ServeMux) {
+	mux.HandleFunc(apiPrefix+"/links", u.requireAPIKeyProblem(u.apiLinks))
+	mux.HandleFunc(apiPrefix+"/links/{path}", u.requireAPIKeyProblem(u.apiLink))
+	mux.HandleFunc(apiPrefix+"/", func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, r, http.StatusNotFound, "No such resource.")
+	})
+}
+
+// apiLinkResponse is a link as returned by the versioned API.
+type apiLinkResponse struct {
+	ShortURL string `json:"short_url"`
+	URLRecord
+	ClickCount int `json:"click_count"`
+	linkState
+}
+
+// apiLinkList is a page of links. NextCursor is set when there are more.
+type apiLinkList struct {
+	Items      []apiLinkResponse `json:"items"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+func newAPILinkResponse(shortURL string, record URLRecord, count int) apiLinkResponse {
+	return apiLinkResponse{
+		ShortURL:   shortURL,
+		URLRecord:  record,
+		ClickCount: count,
+		linkState:  stateOf(record, count, time.Now()),
+	}
+}
+
+func (u *URLStore) apiLinks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		u.apiCreateLink(w, r)
+	case http.MethodGet, http.MethodHead:
+		u.apiListLinks(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodPost)
+	}
+}
+
+func (u *URLStore) apiLink(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		u.apiGetLink(w, r)
+	case http.MethodPatch:
+		u.apiPatchLink(w, r)
+	case http.MethodDelete:
+		if err := u.deleteLink(r.PathValue("path")); err != nil {
+			apiError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodHead, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (u *URLStore) apiCreateLink(w http.ResponseWriter, r *http.Request) {
+	if !hasContentType(r, "application/json") {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "Send the link as application/json.")
+		return
+	}
+
+	var req linkRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	record, err := req.record()
+	if err == nil {
+		req.ShortURL, err = u.createLink(req.ShortURL, record)
+	}
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", apiPrefix+"/links/"+url.PathEscape(req.ShortURL))
+	writeJSON(w, http.StatusCreated, newAPILinkResponse(req.ShortURL, record, 0))
+}
+
+func (u *URLStore) apiListLinks(w http.ResponseWriter, r *http.Request) {
+	limit := defaultPageSize
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxPageSize {
+			writeProblem(w, r, http.StatusBadRequest, "limit must be a number from 1 to "+strconv.Itoa(maxPageSize)+".")
+			return
+		}
+		limit = n
+	}
+
+	// One more link than asked for tells whether there is a next page.
+	links, counts, err := u.listLinks(r.URL.Query().Get("cursor"), limit+1)
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+
+	list := apiLinkList{Items: make([]apiLinkResponse, 0, min(limit, len(links)))}
+	for i, link := range links[:min(limit, len(links))] {
+		list.Items = append(list.Items, newAPILinkResponse(link.ShortURL, link.Record, counts[i]))
+	}
+	if len(links) > limit {
+		list.NextCursor = links[limit-1].ShortURL
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (u *URLStore) apiGetLink(w http.ResponseWriter, r *http.Request) {
+	shortURL := r.PathValue("path")
+	record, count, err := u.getLink(shortURL)
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPILinkResponse(shortURL, record, count))
+}
+
+// linkPatch is a JSON merge patch (RFC 7396) of a link. Fields that are
+// absent are left alone, and null clears a field.
+type linkPatch struct {
+	LongURL      *string         `json:"long_url"`
+	ExpireAt     json.RawMessage `json:"expire_at"`
+	NotBefore    json.RawMessage `json:"not_before"`
+	MaxClicks    json.RawMessage `json:"max_clicks"`
+	RedirectType json.RawMessage `json:"redirect_type"`
+}
+
+// apply changes record according to the patch.
+func (p linkPatch) apply(record *URLRecord) error {
+	if p.LongURL != nil {
+		record.LongURL = *p.LongURL
+	}
+	if p.ExpireAt != nil {
+		t, err := patchTimestamp("expire_at", p.ExpireAt)
+		if err != nil {
+			return err
+		}
+		record.ExpireAt = t
+	}
+	if p.NotBefore != nil {
+		t, err := patchTimestamp("not_before", p.NotBefore)
+		if err != nil {
+			return err
+		}
+		record.NotBefore = t
+	}
+	if p.MaxClicks != nil {
+		if err := patchInt("max_clicks", p.MaxClicks, &record.MaxClicks); err != nil {
+			return err
+		}
+	}
+	if p.RedirectType != nil {
+		if err := patchInt("redirect_type", p.RedirectType, &record.RedirectType); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func patchTimestamp(field string, raw json.RawMessage) (*time.Time, error) {
+	var value *string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil, &fieldError{field, timestampReason}
+	}
+	if value == nil {
+		return nil, nil
+	}
+	t, err := parseTimestamp(*value)
+	if err != nil || t == nil {
+		return nil, &fieldError{field, timestampReason}
+	}
+	return t, nil
+}
+
+// patchInt sets *n from raw, or to zero if raw is null.
+func patchInt(field string, raw json.RawMessage, n *int) error {
+	var value *int
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return &fieldError{field, "must be an integer"}
+	}
+	*n = 0
+	if value != nil {
+		*n = *value
+	}
+	return nil
+}
+
+func (u *URLStore) apiPatchLink(w http.ResponseWriter, r *http.Request) {
+	if !hasContentType(r, "application/merge-patch+json", "application/json") {
+		writeProblem(w, r, http.StatusUnsupportedMediaType, "Send the changes as application/merge-patch+json.")
+		return
+	}
+
+	var patch linkPatch
+	if !decodeJSON(w, r, &patch) {
+		return
+	}
+	shortURL := r.PathValue("path")
+	record, err := u.updateLink(shortURL, patch.apply)
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+
+	_, count, err := u.getLink(shortURL)
+	if err != nil {
+		apiError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAPILinkResponse(shortURL, record, count))
+}
+
+// problem is an RFC 9457 problem details object.
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// InvalidParams names the request fields that failed validation.
+	InvalidParams []invalidParam `json:"invalid_params,omitempty"`
+}
+
+type invalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// writeProblem answers with a problem of the generic type for status.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, detail string, invalid ...invalidParam) {
+	p := problem{
+		Type:          "about:blank",
+		Title:         http.StatusText(status),
+		Status:        status,
+		Detail:        detail,
+		Instance:      r.URL.Path,
+		InvalidParams: invalid,
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// apiError answers a failed request to the versioned API with a problem.
+func apiError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErr *fieldError
+	switch {
+	case errors.Is(err, errLinkNotFound):
+		writeProblem(w, r, http.StatusNotFound, "No link has this short URL.")
+	case errors.Is(err, errLinkExists):
+		writeProblem(w, r, http.StatusConflict, "The short URL is already taken by another link.")
+	case errors.As(err, &fieldErr):
+		writeProblem(w, r, http.StatusUnprocessableEntity, "The link is invalid.",
+			invalidParam{Name: fieldErr.Field, Reason: fieldErr.Reason})
+	default:
+		log.Println("Storage error:", err)
+		writeProblem(w, r, http.StatusInternalServerError, "The link storage failed.")
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeProblem(w, r, http.StatusMethodNotAllowed, r.Method+" is not supported here.")
+}
+
+// hasContentType reports whether the request body has one of the media types.
+func hasContentType(r *http.Request, mediaTypes ...string) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	for _, t := range mediaTypes {
+		if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// decodeJSON decodes the request body into v, answering with a problem if it
+// is malformed or has unknown fields.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			writeProblem(w, r, http.StatusUnprocessableEntity, "The link is invalid.",
+				invalidParam{Name: typeErr.Field, Reason: "must be a " + typeErr.Type.String()})
+			return false
+		}
+		writeProblem(w, r, http.StatusBadRequest, "The body is not valid JSON: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
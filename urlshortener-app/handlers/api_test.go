@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// serveAPI sends a request to the versioned API of store.
+func serveAPI(store *URLStore, method, path, contentType, body string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	store.RegisterAPI(mux)
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		contentType  string
+		body         string
+		wantStatus   int
+		wantParam    string
+		wantAllowHdr string
+	}{
+		{
+			name:        "missing long URL",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"short_url": "b"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantParam:   "long_url",
+		},
+		{
+			name:        "invalid expiry",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"long_url": "https://example.com", "expire_at": "tomorrow"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantParam:   "expire_at",
+		},
+		{
+			name:        "wrong field type",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"long_url": "https://example.com", "max_clicks": "3"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantParam:   "max_clicks",
+		},
+		{
+			name:        "unknown field",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"long_url": "https://example.com", "expires": "2030-01-01T00:00:00Z"}`,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "malformed JSON",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"long_url": `,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:       "missing content type",
+			method:     http.MethodPost,
+			path:       "/api/v1/links",
+			body:       `{"long_url": "https://example.com"}`,
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "taken short URL",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"long_url": "https://example.com", "short_url": "a"}`,
+			wantStatus:  http.StatusConflict,
+		},
+		{
+			name:        "reserved short URL",
+			method:      http.MethodPost,
+			path:        "/api/v1/links",
+			contentType: "application/json",
+			body:        `{"long_url": "https://example.com", "short_url": "api"}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantParam:   "short_url",
+		},
+		{
+			name:       "unknown link",
+			method:     http.MethodGet,
+			path:       "/api/v1/links/missing",
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:        "invalid patch",
+			method:      http.MethodPatch,
+			path:        "/api/v1/links/a",
+			contentType: "application/merge-patch+json",
+			body:        `{"redirect_type": 303}`,
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantParam:   "redirect_type",
+		},
+		{
+			name:       "bad limit",
+			method:     http.MethodGet,
+			path:       "/api/v1/links?limit=0",
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "unsupported method",
+			method:       http.MethodPut,
+			path:         "/api/v1/links/a",
+			wantStatus:   http.StatusMethodNotAllowed,
+			wantAllowHdr: "GET, HEAD, PATCH, DELETE",
+		},
+		{
+			name:       "unknown resource",
+			method:     http.MethodGet,
+			path:       "/api/v1/things",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			if rec := shorten(store, `{"long_url": "https://example.com", "short_url": "a"}`); rec.Code != http.StatusOK {
+				t.Fatalf("shorten: status = %d: %s", rec.Code, rec.Body)
+			}
+
+			rec := serveAPI(store, tt.method, tt.path, tt.contentType, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/problem+json" {
+				t.Errorf("Content-Type = %q, want application/problem+json", got)
+			}
+			if got := rec.Header().Get("Allow"); got != tt.wantAllowHdr {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllowHdr)
+			}
+
+			var p problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatalf("decoding problem: %v", err)
+			}
+			if p.Status != tt.wantStatus || p.Title != http.StatusText(tt.wantStatus) {
+				t.Errorf("problem = %+v, want status %d", p, tt.wantStatus)
+			}
+			switch {
+			case tt.wantParam == "" && len(p.InvalidParams) > 0:
+				t.Errorf("invalid_params = %+v, want none", p.InvalidParams)
+			case tt.wantParam != "" && (len(p.InvalidParams) != 1 || p.InvalidParams[0].Name != tt.wantParam):
+				t.Errorf("invalid_params = %+v, want %s", p.InvalidParams, tt.wantParam)
+			}
+		})
+	}
+}
+
+func TestAPILinks(t *testing.T) {
+	store := newTestStore(t)
+
+	rec := serveAPI(store, http.MethodPost, "/api/v1/links", "application/json",
+		`{"long_url": "https://example.com", "short_url": "a", "max_clicks": 3, "expire_at": "2030-01-01T00:00:00+01:00"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create: status = %d: %s", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Location"); got != "/api/v1/links/a" {
+		t.Errorf("create: Location = %q, want /api/v1/links/a", got)
+	}
+	var created apiLinkResponse
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.ShortURL != "a" || created.ExpireAt.Format("2006-01-02T15:04:05Z07:00") != "2029-12-31T23:00:00Z" ||
+		created.RemainingClicks == nil || *created.RemainingClicks != 3 {
+		t.Errorf("create: response = %+v", created)
+	}
+
+	store.Redirect(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	t.Run("patch", func(t *testing.T) {
+		rec := serveAPI(store, http.MethodPatch, "/api/v1/links/a", "application/merge-patch+json",
+			`{"long_url": "https://example.org", "expire_at": null}`)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		var patched apiLinkResponse
+		if err := json.NewDecoder(rec.Body).Decode(&patched); err != nil {
+			t.Fatal(err)
+		}
+		if patched.LongURL != "https://example.org" || patched.ExpireAt != nil ||
+			patched.MaxClicks != 3 || patched.ClickCount != 1 {
+			t.Errorf("response = %+v", patched)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		for _, shortURL := range []string{"b", "c"} {
+			shorten(store, `{"long_url": "https://example.com", "short_url": "`+shortURL+`"}`)
+		}
+
+		var got []string
+		path := "/api/v1/links?limit=2"
+		for pages := 0; path != ""; pages++ {
+			if pages == 3 {
+				t.Fatal("too many pages")
+			}
+			rec := serveAPI(store, http.MethodGet, path, "", "")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			var list apiLinkList
+			if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range list.Items {
+				got = append(got, item.ShortURL)
+			}
+			path = ""
+			if list.NextCursor != "" {
+				path = "/api/v1/links?limit=2&cursor=" + list.NextCursor
+			}
+		}
+		if strings.Join(got, ",") != "a,b,c" {
+			t.Errorf("listed %v, want [a b c]", got)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if rec := serveAPI(store, http.MethodDelete, "/api/v1/links/a", "", ""); rec.Code != http.StatusNoContent {
+			t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+		}
+		if rec := serveAPI(store, http.MethodGet, "/api/v1/links/a", "", ""); rec.Code != http.StatusNotFound {
+			t.Errorf("get after delete: status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func TestServeOpenAPI(t *testing.T) {
+	rec := httptest.NewRecorder()
+	ServeOpenAPI(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string                    `json:"openapi"`
+		Paths   map[string]map[string]any `json:"paths"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatalf("decoding document: %v", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		t.Errorf("openapi = %q, want 3.x", doc.OpenAPI)
+	}
+	for _, path := range []string{"/shorten", "/links/{path}", "/count/{path}", "/valid/{path}", "/events",
+		"/{path}", "/api/v1/links", "/api/v1/links/{path}"} {
+		if doc.Paths[path] == nil {
+			t.Errorf("path %s is not documented", path)
+		}
+	}
+}
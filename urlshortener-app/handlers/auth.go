@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// APIKeys are the keys that grant access to the management routes. They are
+// read from a file with one key per line, such as a key of a mounted Secret,
+// so that keys can be rotated by adding the new one before removing the old.
+type APIKeys struct {
+	path string
+
+	mu      sync.RWMutex
+	content []byte
+	keys    [][]byte
+}
+
+// LoadAPIKeys reads the keys in the file at path. Blank lines are ignored;
+// a file without any key is an error.
+func LoadAPIKeys(path string) (*APIKeys, error) {
+	k := &APIKeys{path: path}
+	if err := k.reload(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Run reads the file again every interval until stop is closed, so that
+// changes to a mounted Secret take effect without a restart. The previous
+// keys stay in use while the file cannot be read or holds no key.
+func (k *APIKeys) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := k.reload(); err != nil {
+				log.Println("Keeping the previous API keys:", err)
+			}
+		}
+	}
+}
+
+func (k *APIKeys) reload() error {
+	content, err := os.ReadFile(k.path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.RLock()
+	unchanged := k.keys != nil && bytes.Equal(content, k.content)
+	k.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	var keys [][]byte
+	for _, line := range strings.Split(string(content), "\n") {
+		if key := strings.TrimSpace(line); key != "" {
+			keys = append(keys, []byte(key))
+		}
+	}
+	if len(keys) == 0 {
+		return errors.New("no API key in " + k.path)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.keys != nil {
+		log.Println("Reloaded the API keys from", k.path)
+	}
+	k.content = content
+	k.keys = keys
+	return nil
+}
+
+// Valid reports whether key is one of the keys.
+func (k *APIKeys) Valid(key string) bool {
+	if key == "" {
+		return false
+	}
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	valid := false
+	for _, candidate := range k.keys {
+		// Compare with every key in constant time, so that the timing does
+		// not tell how close a guess was.
+		if subtle.ConstantTimeCompare([]byte(key), candidate) == 1 {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// apiKeyHeader is the alternative to an Authorization header for clients
+// that cannot send bearer tokens.
+const apiKeyHeader = "X-API-Key"
+
+// requestAPIKey is the key sent as a bearer token or in the X-API-Key header.
+func requestAPIKey(authorization, apiKey string) string {
+	if scheme, token, ok := strings.Cut(authorization, " "); ok && strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return apiKey
+}
+
+// authorized reports whether r may use the management routes. All requests
+// may when the store has no API keys.
+func (u *URLStore) authorized(r *http.Request) bool {
+	if u.opts.APIKeys == nil {
+		return true
+	}
+	return u.opts.APIKeys.Valid(requestAPIKey(r.Header.Get("Authorization"), r.Header.Get(apiKeyHeader)))
+}
+
+// RequireAPIKey answers requests without a valid API key with 401
+// Unauthorized instead of passing them to next.
+func (u *URLStore) RequireAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !u.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="urlshortener"`)
+			http.Error(w, "Missing or invalid API key", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireAPIKeyProblem is RequireAPIKey for the versioned API, which answers
+// with a problem.
+func (u *URLStore) requireAPIKeyProblem(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !u.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="urlshortener"`)
+			writeProblem(w, r, http.StatusUnauthorized, "A valid API key is required.")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// authorizeGRPC checks the API key of a gRPC call, sent in the
+// authorization or x-api-key metadata like the HTTP headers.
+func (u *URLStore) authorizeGRPC(ctx context.Context) error {
+	if u.opts.APIKeys == nil {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	key := requestAPIKey(firstValue(md, "authorization"), firstValue(md, strings.ToLower(apiKeyHeader)))
+	if !u.opts.APIKeys.Valid(key) {
+		return status.Error(codes.Unauthenticated, "missing or invalid API key")
+	}
+	return nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
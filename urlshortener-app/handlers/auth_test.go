@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"urlshortener/shortenerpb"
+)
+
+// writeAPIKeys writes content to a keys file and returns its path.
+func writeAPIKeys(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "api-keys")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newAuthStore returns a store accepting the keys "old" and "new".
+func newAuthStore(t *testing.T) *URLStore {
+	t.Helper()
+	keys, err := LoadAPIKeys(writeAPIKeys(t, "old\n\n  new  \n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := newTestStore(t)
+	store.opts.APIKeys = keys
+	return store
+}
+
+func TestRequireAPIKey(t *testing.T) {
+	store := newAuthStore(t)
+	handler := store.RequireAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		apiKey        string
+		wantStatus    int
+	}{
+		{name: "no key", wantStatus: http.StatusUnauthorized},
+		{name: "bearer token", authorization: "Bearer new", wantStatus: http.StatusNoContent},
+		{name: "lower case scheme", authorization: "bearer old", wantStatus: http.StatusNoContent},
+		{name: "API key header", apiKey: "old", wantStatus: http.StatusNoContent},
+		{name: "wrong key", authorization: "Bearer guess", wantStatus: http.StatusUnauthorized},
+		{name: "prefix of a key", apiKey: "ne", wantStatus: http.StatusUnauthorized},
+		{name: "other scheme", authorization: "Basic bmV3Og==", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/shorten", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("WWW-Authenticate is not set")
+			}
+		})
+	}
+}
+
+func TestAPIRequiresAPIKey(t *testing.T) {
+	store := newAuthStore(t)
+
+	rec := serveAPI(store, http.MethodGet, "/api/v1/links", "", "")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Fatalf("without key: %d %s, want a 401 problem", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	mux := http.NewServeMux()
+	store.RegisterAPI(mux)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/links", nil)
+	req.Header.Set("Authorization", "Bearer old")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("with key: %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestGRPCRequiresAPIKey(t *testing.T) {
+	client := newGRPCClient(t, newAuthStore(t))
+
+	_, err := client.GetStats(context.Background(), &shortenerpb.GetStatsRequest{ShortUrl: "a"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without key: %v, want Unauthenticated", err)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer new")
+	_, err = client.GetStats(ctx, &shortenerpb.GetStatsRequest{ShortUrl: "a"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("with key: %v, want NotFound", err)
+	}
+}
+
+func TestAPIKeysReload(t *testing.T) {
+	path := writeAPIKeys(t, "old\n")
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("new\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.reload(); err != nil {
+		t.Fatal(err)
+	}
+	if keys.Valid("old") || !keys.Valid("new") {
+		t.Error("keys were not replaced by the new content of the file")
+	}
+
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.reload(); err == nil {
+		t.Error("reloading an empty file succeeded")
+	}
+	if !keys.Valid("new") {
+		t.Error("keys were dropped by a failed reload")
+	}
+
+	if _, err := LoadAPIKeys(writeAPIKeys(t, "")); err == nil {
+		t.Error("loading an empty file succeeded")
+	}
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// keepaliveInterval is how often an idle event stream gets a comment line, so
+// that proxies do not close it.
+const keepaliveInterval = 30 * time.Second
+
+// ClickEvent reports the clicks a link got since the previous event.
+type ClickEvent struct {
+	ShortURL string `json:"short_url"`
+	// Clicks is the number of clicks since the previous event of the link.
+	Clicks int `json:"clicks"`
+	// ClickCount is the total click count of the link. Consumers that missed
+	// events can rely on it instead of adding up Clicks.
+	ClickCount int `json:"click_count"`
+}
+
+// EventFeed batches clicks and streams them to subscribers as server-sent
+// events. Clicks are collected for one flush interval and then sent as a
+// single batch with one event per link.
+type EventFeed struct {
+	mu          sync.Mutex
+	pending     map[string]*ClickEvent
+	subscribers map[chan []ClickEvent]struct{}
+}
+
+func NewEventFeed() *EventFeed {
+	return &EventFeed{
+		pending:     map[string]*ClickEvent{},
+		subscribers: map[chan []ClickEvent]struct{}{},
+	}
+}
+
+// Click records a click on shortURL, whose total count is now clickCount. It
+// is a no-op on a nil feed.
+func (f *EventFeed) Click(shortURL string, clickCount int) {
+	if f == nil {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.subscribers) == 0 {
+		return
+	}
+	event, ok := f.pending[shortURL]
+	if !ok {
+		event = &ClickEvent{ShortURL: shortURL}
+		f.pending[shortURL] = event
+	}
+	event.Clicks++
+	event.ClickCount = clickCount
+}
+
+// Run sends the pending clicks to all subscribers every interval until stop
+// is closed.
+func (f *EventFeed) Run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			f.flush()
+		}
+	}
+}
+
+func (f *EventFeed) flush() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.pending) == 0 {
+		return
+	}
+
+	batch := make([]ClickEvent, 0, len(f.pending))
+	for _, event := range f.pending {
+		batch = append(batch, *event)
+	}
+	clear(f.pending)
+
+	for ch := range f.subscribers {
+		// A subscriber that is still busy with the previous batch misses
+		// this one. The totals in the next batch make up for it.
+		select {
+		case ch <- batch:
+		default:
+		}
+	}
+}
+
+func (f *EventFeed) subscribe() (<-chan []ClickEvent, func()) {
+	ch := make(chan []ClickEvent, 1)
+
+	f.mu.Lock()
+	f.subscribers[ch] = struct{}{}
+	f.mu.Unlock()
+
+	return ch, func() {
+		f.mu.Lock()
+		delete(f.subscribers, ch)
+		f.mu.Unlock()
+	}
+}
+
+// ServeHTTP streams click events as server-sent events of type "clicks",
+// each carrying a JSON array of ClickEvent.
+func (f *EventFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	events, unsubscribe := f.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		case batch := <-events:
+			data, err := json.Marshal(batch)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: clicks\ndata: %s\n\n", data)
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Base62Alphabet is the default alphabet of generated short paths.
+const Base62Alphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+const (
+	// collisionAttempts is how many codes are tried at one length before the
+	// keyspace is considered crowded and the length grows.
+	collisionAttempts = 5
+	// maxCodeLength bounds the automatic length growth.
+	maxCodeLength = 32
+)
+
+var errKeyspaceExhausted = errors.New("no free short URL left")
+
+// CodeGenerator produces cryptographically random short paths.
+type CodeGenerator struct {
+	alphabet []rune
+	length   int
+}
+
+func NewCodeGenerator(alphabet string, length int) (*CodeGenerator, error) {
+	runes := []rune(alphabet)
+
+	seen := make(map[rune]bool, len(runes))
+	for _, r := range runes {
+		if seen[r] {
+			return nil, fmt.Errorf("alphabet contains %q twice", r)
+		}
+		seen[r] = true
+	}
+	if len(runes) < 2 {
+		return nil, errors.New("alphabet needs at least two characters")
+	}
+	if strings.ContainsAny(alphabet, "/?#") {
+		return nil, errors.New("alphabet must not contain '/', '?' or '#'")
+	}
+	if length < 1 || length > maxCodeLength {
+		return nil, fmt.Errorf("length must be between 1 and %d", maxCodeLength)
+	}
+
+	return &CodeGenerator{alphabet: runes, length: length}, nil
+}
+
+// Generate returns a random code of the current length.
+func (g *CodeGenerator) Generate() (string, error) {
+	max := big.NewInt(int64(len(g.alphabet)))
+
+	b := make([]rune, g.length)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = g.alphabet[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// grow makes future codes one character longer. It reports false once the
+// maximum length has been reached.
+func (g *CodeGenerator) grow() bool {
+	if g.length >= maxCodeLength {
+		return false
+	}
+	g.length++
+	return true
+}
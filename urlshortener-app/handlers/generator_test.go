@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewCodeGenerator(t *testing.T) {
+	tests := []struct {
+		name     string
+		alphabet string
+		length   int
+		wantErr  bool
+	}{
+		{name: "base62", alphabet: Base62Alphabet, length: 6},
+		{name: "two characters", alphabet: "ab", length: 1},
+		{name: "multi-byte characters", alphabet: "äöü", length: 4},
+		{name: "longest codes", alphabet: "ab", length: maxCodeLength},
+		{name: "empty alphabet", alphabet: "", length: 6, wantErr: true},
+		{name: "single character", alphabet: "a", length: 6, wantErr: true},
+		{name: "repeated character", alphabet: "abca", length: 6, wantErr: true},
+		{name: "slash", alphabet: "ab/", length: 6, wantErr: true},
+		{name: "query", alphabet: "ab?", length: 6, wantErr: true},
+		{name: "fragment", alphabet: "ab#", length: 6, wantErr: true},
+		{name: "zero length", alphabet: "ab", length: 0, wantErr: true},
+		{name: "too long", alphabet: "ab", length: maxCodeLength + 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewCodeGenerator(tt.alphabet, tt.length)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewCodeGenerator(%q, %d) error = %v, wantErr %v", tt.alphabet, tt.length, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			code, err := generator.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len([]rune(code)); n != tt.length {
+				t.Errorf("code %q has %d characters, want %d", code, n, tt.length)
+			}
+			for _, r := range code {
+				if !strings.ContainsRune(tt.alphabet, r) {
+					t.Errorf("code %q contains %q, which is not in the alphabet", code, r)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateShortURL(t *testing.T) {
+	tests := []struct {
+		name       string
+		taken      []string
+		wantLength []int
+	}{
+		{name: "free keyspace", wantLength: []int{1}},
+		// Five collisions in a row with "a" are unlikely but possible, in
+		// which case the generator grows instead of finding "b".
+		{name: "retries collisions", taken: []string{"a"}, wantLength: []int{1, 2}},
+		{name: "grows a full keyspace", taken: []string{"a", "b"}, wantLength: []int{2}},
+		{name: "grows twice", taken: []string{"a", "b", "aa", "ab", "ba", "bb"}, wantLength: []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			generator, err := NewCodeGenerator("ab", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store := &URLStore{storage: NewMemoryStorage(), generator: generator}
+			for _, shortURL := range tt.taken {
+				if err := store.storage.Put(shortURL, URLRecord{LongURL: "https://example.com"}); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			shortURL, err := store.generateShortURL()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, taken := range tt.taken {
+				if shortURL == taken {
+					t.Fatalf("generated %q, which is taken", shortURL)
+				}
+			}
+			if !slices.Contains(tt.wantLength, len(shortURL)) {
+				t.Errorf("generated %q, want a length in %v", shortURL, tt.wantLength)
+			}
+			if generator.length != len(shortURL) {
+				t.Errorf("generator length = %d, want %d", generator.length, len(shortURL))
+			}
+		})
+	}
+}
+
+func TestCodeGeneratorGrowStopsAtMaxLength(t *testing.T) {
+	generator, err := NewCodeGenerator("ab", maxCodeLength-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !generator.grow() || generator.length != maxCodeLength {
+		t.Fatalf("grow below the maximum length left length %d", generator.length)
+	}
+	if generator.grow() || generator.length != maxCodeLength {
+		t.Fatalf("grow at the maximum length succeeded, length %d", generator.length)
+	}
+}
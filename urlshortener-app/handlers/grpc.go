@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"urlshortener/shortenerpb"
+)
+
+// RegisterGRPC serves the gRPC API of the store on s. It shares its logic,
+// and so its validation and errors, with the HTTP API. Every call requires an
+// API key if the store has any.
+func (u *URLStore) RegisterGRPC(s grpc.ServiceRegistrar) {
+	shortenerpb.RegisterShortenerServer(s, &grpcServer{store: u})
+}
+
+type grpcServer struct {
+	shortenerpb.UnimplementedShortenerServer
+	store *URLStore
+}
+
+func (s *grpcServer) Shorten(ctx context.Context, req *shortenerpb.ShortenRequest) (*shortenerpb.Link, error) {
+	if err := s.store.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	record, err := recordFromProto(req.GetLongUrl(), req.GetExpireAt(), req.GetNotBefore(),
+		req.GetMaxClicks(), req.GetRedirectType())
+	shortURL := req.GetShortUrl()
+	if err == nil {
+		shortURL, err = s.store.createLink(shortURL, record)
+	}
+	if err != nil {
+		return nil, s.store.grpcError(err, record)
+	}
+	return linkToProto(shortURL, record), nil
+}
+
+func (s *grpcServer) Update(ctx context.Context, req *shortenerpb.UpdateRequest) (*shortenerpb.Link, error) {
+	if err := s.store.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	replacement, err := recordFromProto(req.GetLongUrl(), req.GetExpireAt(), req.GetNotBefore(),
+		req.GetMaxClicks(), req.GetRedirectType())
+	if err == nil {
+		replacement, err = s.store.updateLink(req.GetShortUrl(), func(record *URLRecord) error {
+			*record = replacement
+			return nil
+		})
+	}
+	if err != nil {
+		return nil, s.store.grpcError(err, replacement)
+	}
+	return linkToProto(req.GetShortUrl(), replacement), nil
+}
+
+func (s *grpcServer) Resolve(ctx context.Context, req *shortenerpb.ResolveRequest) (*shortenerpb.ResolveResponse, error) {
+	if err := s.store.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	var record URLRecord
+	var count int
+	var err error
+	if req.GetCountClick() {
+		record, count, err = s.store.followLink(req.GetShortUrl())
+	} else {
+		record, count, err = s.store.getLink(req.GetShortUrl())
+	}
+	if err != nil {
+		return nil, s.store.grpcError(err, record)
+	}
+
+	state := stateOf(record, count, time.Now())
+	response := &shortenerpb.ResolveResponse{
+		Link: linkToProto(req.GetShortUrl(), record),
+		State: &shortenerpb.LinkState{
+			IsValid:  state.IsValid,
+			IsActive: state.IsActive,
+		},
+		ClickCount: int64(count),
+	}
+	if state.RemainingClicks != nil {
+		remaining := int32(*state.RemainingClicks)
+		response.State.RemainingClicks = &remaining
+	}
+	return response, nil
+}
+
+func (s *grpcServer) GetStats(ctx context.Context, req *shortenerpb.GetStatsRequest) (*shortenerpb.Stats, error) {
+	if err := s.store.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	record, count, err := s.store.getLink(req.GetShortUrl())
+	if err != nil {
+		return nil, s.store.grpcError(err, record)
+	}
+	return &shortenerpb.Stats{ClickCount: int64(count)}, nil
+}
+
+func (s *grpcServer) Delete(ctx context.Context, req *shortenerpb.DeleteRequest) (*shortenerpb.DeleteResponse, error) {
+	if err := s.store.authorizeGRPC(ctx); err != nil {
+		return nil, err
+	}
+	if err := s.store.deleteLink(req.GetShortUrl()); err != nil {
+		return nil, s.store.grpcError(err, URLRecord{})
+	}
+	return &shortenerpb.DeleteResponse{}, nil
+}
+
+func (s *grpcServer) WatchClicks(req *shortenerpb.WatchClicksRequest, stream grpc.ServerStreamingServer[shortenerpb.ClickBatch]) error {
+	if err := s.store.authorizeGRPC(stream.Context()); err != nil {
+		return err
+	}
+	feed := s.store.opts.Events
+	if feed == nil {
+		return status.Error(codes.Unavailable, "click events are disabled")
+	}
+
+	events, unsubscribe := feed.subscribe()
+	defer unsubscribe()
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case batch := <-events:
+			message := &shortenerpb.ClickBatch{Events: make([]*shortenerpb.ClickEvent, 0, len(batch))}
+			for _, event := range batch {
+				message.Events = append(message.Events, &shortenerpb.ClickEvent{
+					ShortUrl:   event.ShortURL,
+					Clicks:     int64(event.Clicks),
+					ClickCount: int64(event.ClickCount),
+				})
+			}
+			if err := stream.Send(message); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcError converts an error of the link logic to a gRPC status. record is
+// the link the error is about, if known.
+func (u *URLStore) grpcError(err error, record URLRecord) error {
+	var fieldErr *fieldError
+	switch {
+	case errors.Is(err, errLinkNotFound):
+		return status.Error(codes.NotFound, "short URL not found")
+	case errors.Is(err, errLinkExists):
+		return status.Error(codes.AlreadyExists, "short URL already exists")
+	case errors.As(err, &fieldErr):
+		st, detailErr := status.New(codes.InvalidArgument, "invalid "+fieldErr.Error()).WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: fieldErr.Field, Description: fieldErr.Reason},
+			},
+		})
+		if detailErr != nil {
+			return status.Error(codes.InvalidArgument, "invalid "+fieldErr.Error())
+		}
+		return st.Err()
+	case errors.Is(err, errLinkExpired):
+		return status.Error(codes.FailedPrecondition, "link expired at "+u.displayTime(*record.ExpireAt))
+	case errors.Is(err, errLinkInactive):
+		return status.Error(codes.FailedPrecondition, "link not active until "+u.displayTime(*record.NotBefore))
+	case errors.Is(err, errClickLimitReached):
+		return status.Error(codes.FailedPrecondition, "link click limit reached")
+	default:
+		log.Println("Storage error:", err)
+		return status.Error(codes.Internal, "internal server error")
+	}
+}
+
+// recordFromProto builds and validates the record described by the fields of
+// a request.
+func recordFromProto(longURL string, expireAt, notBefore *timestamppb.Timestamp, maxClicks, redirectType int32) (URLRecord, error) {
+	record := URLRecord{
+		LongURL:      longURL,
+		MaxClicks:    int(maxClicks),
+		RedirectType: int(redirectType),
+	}
+	var err error
+	if record.ExpireAt, err = timeFromProto(expireAt); err != nil {
+		return URLRecord{}, &fieldError{"expire_at", "must be a valid timestamp"}
+	}
+	if record.NotBefore, err = timeFromProto(notBefore); err != nil {
+		return URLRecord{}, &fieldError{"not_before", "must be a valid timestamp"}
+	}
+	return record, validateRecord(record)
+}
+
+func timeFromProto(ts *timestamppb.Timestamp) (*time.Time, error) {
+	if ts == nil {
+		return nil, nil
+	}
+	if err := ts.CheckValid(); err != nil {
+		return nil, err
+	}
+	t := ts.AsTime().UTC()
+	return &t, nil
+}
+
+func linkToProto(shortURL string, record URLRecord) *shortenerpb.Link {
+	link := &shortenerpb.Link{
+		ShortUrl:     shortURL,
+		LongUrl:      record.LongURL,
+		MaxClicks:    int32(record.MaxClicks),
+		RedirectType: int32(record.RedirectType),
+	}
+	if record.ExpireAt != nil {
+		link.ExpireAt = timestamppb.New(*record.ExpireAt)
+	}
+	if record.NotBefore != nil {
+		link.NotBefore = timestamppb.New(*record.NotBefore)
+	}
+	return link
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"urlshortener/shortenerpb"
+)
+
+// newGRPCClient serves the gRPC API of store in memory and returns a client
+// for it.
+func newGRPCClient(t *testing.T, store *URLStore) shortenerpb.ShortenerClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	store.RegisterGRPC(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return shortenerpb.NewShortenerClient(conn)
+}
+
+func TestGRPCErrors(t *testing.T) {
+	ctx := context.Background()
+	client := newGRPCClient(t, newTestStore(t))
+	if _, err := client.Shorten(ctx, &shortenerpb.ShortenRequest{LongUrl: "https://example.com", ShortUrl: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Shorten(ctx, &shortenerpb.ShortenRequest{
+		LongUrl:  "https://example.com",
+		ShortUrl: "expired",
+		ExpireAt: timestamppb.New(time.Now().Add(-time.Minute)),
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		call      func() error
+		wantCode  codes.Code
+		wantField string
+	}{
+		{
+			name: "missing long URL",
+			call: func() error {
+				_, err := client.Shorten(ctx, &shortenerpb.ShortenRequest{ShortUrl: "b"})
+				return err
+			},
+			wantCode:  codes.InvalidArgument,
+			wantField: "long_url",
+		},
+		{
+			name: "invalid redirect type",
+			call: func() error {
+				_, err := client.Update(ctx, &shortenerpb.UpdateRequest{ShortUrl: "a", LongUrl: "https://example.com", RedirectType: 303})
+				return err
+			},
+			wantCode:  codes.InvalidArgument,
+			wantField: "redirect_type",
+		},
+		{
+			name: "reserved short URL",
+			call: func() error {
+				_, err := client.Shorten(ctx, &shortenerpb.ShortenRequest{LongUrl: "https://example.com", ShortUrl: "shorten"})
+				return err
+			},
+			wantCode:  codes.InvalidArgument,
+			wantField: "short_url",
+		},
+		{
+			name: "taken short URL",
+			call: func() error {
+				_, err := client.Shorten(ctx, &shortenerpb.ShortenRequest{LongUrl: "https://example.com", ShortUrl: "a"})
+				return err
+			},
+			wantCode: codes.AlreadyExists,
+		},
+		{
+			name: "unknown link",
+			call: func() error {
+				_, err := client.GetStats(ctx, &shortenerpb.GetStatsRequest{ShortUrl: "missing"})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "following an expired link",
+			call: func() error {
+				_, err := client.Resolve(ctx, &shortenerpb.ResolveRequest{ShortUrl: "expired", CountClick: true})
+				return err
+			},
+			wantCode: codes.FailedPrecondition,
+		},
+		{
+			name: "watching without events",
+			call: func() error {
+				stream, err := client.WatchClicks(ctx, &shortenerpb.WatchClicksRequest{})
+				if err == nil {
+					_, err = stream.Recv()
+				}
+				return err
+			},
+			wantCode: codes.Unavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := status.Convert(tt.call())
+			if st.Code() != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %s", st.Code(), tt.wantCode, st.Message())
+			}
+			var fields []string
+			for _, detail := range st.Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.GetFieldViolations() {
+						fields = append(fields, violation.GetField())
+					}
+				}
+			}
+			if tt.wantField != "" && (len(fields) != 1 || fields[0] != tt.wantField) {
+				t.Errorf("field violations = %v, want %s", fields, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestGRPCLinks(t *testing.T) {
+	ctx := context.Background()
+	store := newTestStore(t)
+	store.opts.Events = NewEventFeed()
+	client := newGRPCClient(t, store)
+
+	expireAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	link, err := client.Shorten(ctx, &shortenerpb.ShortenRequest{
+		LongUrl:   "https://example.com",
+		ExpireAt:  timestamppb.New(expireAt),
+		MaxClicks: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.GetShortUrl() == "" || !link.GetExpireAt().AsTime().Equal(expireAt) {
+		t.Errorf("Shorten = %v", link)
+	}
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := client.WatchClicks(watchCtx, &shortenerpb.WatchClicksRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The subscription is made when the server gets the call. Wait for it so
+	// that the click below is not missed.
+	for !hasSubscribers(store.opts.Events) {
+		time.Sleep(time.Millisecond)
+	}
+
+	resolved, err := client.Resolve(ctx, &shortenerpb.ResolveRequest{ShortUrl: link.GetShortUrl(), CountClick: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.GetClickCount() != 1 || resolved.GetState().GetRemainingClicks() != 1 || !resolved.GetState().GetIsValid() {
+		t.Errorf("Resolve = %v", resolved)
+	}
+
+	store.opts.Events.flush()
+	batch, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.GetEvents()) != 1 || batch.GetEvents()[0].GetClickCount() != 1 {
+		t.Errorf("WatchClicks = %v", batch)
+	}
+
+	stats, err := client.GetStats(ctx, &shortenerpb.GetStatsRequest{ShortUrl: link.GetShortUrl()})
+	if err != nil || stats.GetClickCount() != 1 {
+		t.Errorf("GetStats = %v, %v", stats, err)
+	}
+
+	if _, err := client.Delete(ctx, &shortenerpb.DeleteRequest{ShortUrl: link.GetShortUrl()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Resolve(ctx, &shortenerpb.ResolveRequest{ShortUrl: link.GetShortUrl()}); status.Code(err) != codes.NotFound {
+		t.Errorf("Resolve after Delete: %v, want NotFound", err)
+	}
+}
+
+func hasSubscribers(f *EventFeed) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.subscribers) > 0
+}
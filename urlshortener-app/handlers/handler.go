@@ -2,66 +2,137 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
 )
 
-const timeDiff = 3*3600 + 30*60
-
+// URLRecord is a stored link. All times are in UTC.
 type URLRecord struct {
-	LongURL  string
-	ExpireAt *time.Time
+	LongURL  string     `json:"long_url"`
+	ExpireAt *time.Time `json:"expire_at,omitempty"`
+	// NotBefore is when the link starts to redirect. Until then Redirect
+	// answers with the InactiveResponse of the store.
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// MaxClicks is how often the link redirects before it behaves as
+	// expired. Zero means no limit.
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// RedirectType is the status code Redirect answers with: 301, 302, 307
+	// or 308. Zero means 302.
+	RedirectType int `json:"redirect_type,omitempty"`
+}
+
+// InactiveResponse is what Redirect answers for links whose not_before time
+// has not come yet.
+type InactiveResponse struct {
+	StatusCode int
+	// Page is served as HTML when set, e.g. a placeholder announcing the
+	// launch. A plain text message is sent otherwise.
+	Page []byte
+}
+
+// Options configure the optional behavior of a URLStore. The zero value is
+// usable.
+type Options struct {
+	// Events is told about every click. It may be nil.
+	Events *EventFeed
+	// Inactive is answered for links that are not active yet.
+	Inactive InactiveResponse
+	// PermanentMaxAge is how long clients may cache permanent redirects.
+	PermanentMaxAge time.Duration
+	// DisplayZone is the time zone of times in messages and logs meant for
+	// people. The API itself always uses UTC. Defaults to UTC.
+	DisplayZone *time.Location
+	// APIKeys are required on the management routes wrapped with
+	// RequireAPIKey, the versioned API and the gRPC API. They are open to all
+	// when nil.
+	APIKeys *APIKeys
 }
 
 type URLStore struct {
-	mu    sync.Mutex
-	store map[string]URLRecord
-	count map[string]int
+	mu        sync.Mutex
+	storage   Storage
+	generator *CodeGenerator
+	opts      Options
 }
 
-func NewURLStore() *URLStore {
+func NewURLStore(storage Storage, generator *CodeGenerator, opts Options) *URLStore {
+	if opts.DisplayZone == nil {
+		opts.DisplayZone = time.UTC
+	}
 	return &URLStore{
-		store: make(map[string]URLRecord),
-		count: make(map[string]int),
+		storage:   storage,
+		generator: generator,
+		opts:      opts,
 	}
 }
 
+// The handlers below serve the original, unversioned routes. They are kept
+// for existing clients such as the operator and share their logic with the
+// versioned API.
+
 func (u *URLStore) ShortenURL(w http.ResponseWriter, r *http.Request) {
-	var req struct {
-		LongURL  string `json:"long_url"`
-		ExpireAt string `json:"expire_at,omitempty"` // "2025-03-01T15:04:05Z"
-	}
+	var req linkRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	var expireAt *time.Time
+	record, err := req.record()
+	if err == nil {
+		req.ShortURL, err = u.createLink(req.ShortURL, record)
+	}
+	if err != nil {
+		legacyError(w, r, err)
+		return
+	}
 
-	if req.ExpireAt != "" {
-		loc := time.FixedZone("Local", timeDiff)
-		expireStr := req.ExpireAt
-		if strings.HasSuffix(expireStr, "Z") {
-			expireStr = strings.TrimSuffix(expireStr, "Z")
-		}
-		parsedTime, err := time.ParseInLocation("2006-01-02T15:04:05", expireStr, loc)
-		if err != nil {
-			http.Error(w, "Invalid expiration date format. Use format: YYYY-MM-DDTHH:MM:SS", http.StatusBadRequest)
-			return
-		}
-		expireAt = &parsedTime
+	response := map[string]string{"short_url": req.ShortURL}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (u *URLStore) GetURL(w http.ResponseWriter, r *http.Request) {
+	shortURL := r.PathValue("path")
+
+	record, _, err := u.getLink(shortURL)
+	if err != nil {
+		legacyError(w, r, err)
+		return
 	}
 
-	u.mu.Lock()
-	shortURL := generateShortURL()
-	u.store[shortURL] = URLRecord{
-		LongURL:  req.LongURL,
-		ExpireAt: expireAt,
+	response := struct {
+		ShortURL string `json:"short_url"`
+		URLRecord
+	}{shortURL, record}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// UpdateURL replaces the link stored under the short URL.
+func (u *URLStore) UpdateURL(w http.ResponseWriter, r *http.Request) {
+	shortURL := r.PathValue("path")
+
+	var req linkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	replacement, err := req.record()
+	if err == nil {
+		_, err = u.updateLink(shortURL, func(record *URLRecord) error {
+			*record = replacement
+			return nil
+		})
+	}
+	if err != nil {
+		legacyError(w, r, err)
+		return
 	}
-	u.mu.Unlock()
 
 	response := map[string]string{"short_url": shortURL}
 	w.Header().Set("Content-Type", "application/json")
@@ -69,37 +140,68 @@ func (u *URLStore) ShortenURL(w http.ResponseWriter, r *http.Request) {
 }
 
 func (u *URLStore) Redirect(w http.ResponseWriter, r *http.Request) {
-	shortURL := r.URL.Path[1:]
-
-	u.mu.Lock()
-	record, exists := u.store[shortURL]
-	if exists {
-		if record.ExpireAt != nil && time.Until(*record.ExpireAt) < 0 {
-			u.mu.Unlock()
-			http.Error(w, "URL expired", http.StatusGone)
-			return
-		}
-		u.count[shortURL]++
-	}
-	u.mu.Unlock()
-
-	if !exists {
+	record, _, err := u.followLink(r.URL.Path[1:])
+	switch {
+	case errors.Is(err, errLinkNotFound):
 		http.NotFound(w, r)
 		return
+	case errors.Is(err, errLinkExpired):
+		http.Error(w, "URL expired at "+u.displayTime(*record.ExpireAt), http.StatusGone)
+		return
+	case errors.Is(err, errLinkInactive):
+		u.writeInactive(w, *record.NotBefore)
+		return
+	case errors.Is(err, errClickLimitReached):
+		http.Error(w, "URL click limit reached", http.StatusGone)
+		return
+	case err != nil:
+		storageError(w, err)
+		return
 	}
 
-	http.Redirect(w, r, record.LongURL, http.StatusFound)
+	status := record.RedirectType
+	if status == 0 {
+		status = http.StatusFound
+	}
+	if cacheControl := u.cacheControl(record); cacheControl != "" {
+		w.Header().Set("Cache-Control", cacheControl)
+	}
+	http.Redirect(w, r, record.LongURL, status)
+}
+
+// cacheControl returns the Cache-Control header of a redirect to record.
+// Permanent redirects are cached by browsers indefinitely unless told
+// otherwise, which would outlive edits and expiry of the link and hide
+// clicks from the count. Their lifetime is bounded by PermanentMaxAge and the
+// expiry of the link, and links with a click limit are not cached at all.
+func (u *URLStore) cacheControl(record URLRecord) string {
+	if record.RedirectType != http.StatusMovedPermanently && record.RedirectType != http.StatusPermanentRedirect {
+		return ""
+	}
+	if record.MaxClicks > 0 {
+		return "no-store"
+	}
+	maxAge := u.opts.PermanentMaxAge
+	if record.ExpireAt != nil {
+		maxAge = min(maxAge, time.Until(*record.ExpireAt))
+	}
+	return fmt.Sprintf("public, max-age=%d", max(int(maxAge.Seconds()), 0))
+}
+
+func (u *URLStore) DeleteURL(w http.ResponseWriter, r *http.Request) {
+	if err := u.deleteLink(r.PathValue("path")); err != nil {
+		legacyError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func (u *URLStore) GetCount(w http.ResponseWriter, r *http.Request) {
 	shortURL := r.URL.Path[len("/count/"):]
 
-	u.mu.Lock()
-	count, exists := u.count[shortURL]
-	u.mu.Unlock()
-
-	if !exists {
-		http.NotFound(w, r)
+	_, count, err := u.getLink(shortURL)
+	if err != nil {
+		legacyError(w, r, err)
 		return
 	}
 
@@ -111,25 +213,91 @@ func (u *URLStore) GetCount(w http.ResponseWriter, r *http.Request) {
 func (u *URLStore) CheckValidity(w http.ResponseWriter, r *http.Request) {
 	shortURL := r.URL.Path[len("/valid/"):]
 
-	u.mu.Lock()
-	record, exists := u.store[shortURL]
-	u.mu.Unlock()
-
-	if !exists {
-		http.NotFound(w, r)
+	record, count, err := u.getLink(shortURL)
+	if err != nil {
+		legacyError(w, r, err)
 		return
 	}
 
 	if record.ExpireAt != nil {
-		log.Println("Time until expiration:", time.Until(*record.ExpireAt))
+		log.Println("Link", shortURL, "expires at", u.displayTime(*record.ExpireAt))
 	}
 
-	isValid := true
-	if record.ExpireAt != nil && time.Until(*record.ExpireAt) < 0 {
-		isValid = false
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stateOf(record, count, time.Now()))
+}
+
+// legacyError answers a failed request to one of the unversioned routes with
+// the plain text errors they have always used.
+func legacyError(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErr *fieldError
+	switch {
+	case errors.Is(err, errLinkNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, errLinkExists):
+		http.Error(w, "Short URL already exists", http.StatusConflict)
+	case errors.As(err, &fieldErr) && fieldErr.Field == "short_url":
+		http.Error(w, "Short URL is reserved or contains invalid characters", http.StatusUnprocessableEntity)
+	case errors.As(err, &fieldErr):
+		http.Error(w, "Invalid "+fieldErr.Error(), http.StatusBadRequest)
+	default:
+		storageError(w, err)
 	}
+}
 
-	response := map[string]bool{"is_valid": isValid}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+// writeInactive answers a redirect to a link that is not active until
+// notBefore.
+func (u *URLStore) writeInactive(w http.ResponseWriter, notBefore time.Time) {
+	status := u.opts.Inactive.StatusCode
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	if u.opts.Inactive.Page == nil {
+		http.Error(w, "URL not active until "+u.displayTime(notBefore), status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write(u.opts.Inactive.Page)
+}
+
+// displayTime formats t for people, in the display zone of the store.
+func (u *URLStore) displayTime(t time.Time) string {
+	return t.In(u.opts.DisplayZone).Format("2006-01-02 15:04:05 MST")
+}
+
+func storageError(w http.ResponseWriter, err error) {
+	log.Println("Storage error:", err)
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+// generateShortURL returns a random short path that is not in use yet. When
+// several attempts in a row collide with existing links, the keyspace is
+// crowded and the generator switches to longer codes. The caller must hold
+// u.mu so that the returned path is still free when it is stored.
+func (u *URLStore) generateShortURL() (string, error) {
+	for {
+		for attempt := 0; attempt < collisionAttempts; attempt++ {
+			shortURL, err := u.generator.Generate()
+			if err != nil {
+				return "", err
+			}
+			if !validShortURL(shortURL) {
+				continue
+			}
+
+			_, exists, err := u.storage.Get(shortURL)
+			if err != nil {
+				return "", err
+			}
+			if !exists {
+				return shortURL, nil
+			}
+		}
+
+		if !u.generator.grow() {
+			return "", errKeyspaceExhausted
+		}
+		log.Println("Short URL keyspace is crowded, growing code length to", u.generator.length)
+	}
 }
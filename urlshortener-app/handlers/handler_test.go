@@ -0,0 +1,445 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestStore(t *testing.T) *URLStore {
+	t.Helper()
+	generator, err := NewCodeGenerator(Base62Alphabet, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewURLStore(NewMemoryStorage(), generator, Options{})
+}
+
+// shorten registers a link through the ShortenURL handler and returns the
+// response.
+func shorten(store *URLStore, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/shorten", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	store.ShortenURL(rec, req)
+	return rec
+}
+
+func TestShortenURL(t *testing.T) {
+	want := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantExpireAt *time.Time
+	}{
+		{
+			name:         "UTC",
+			body:         `{"long_url": "https://example.com", "short_url": "a", "expire_at": "2030-01-02T03:04:05Z"}`,
+			wantStatus:   http.StatusOK,
+			wantExpireAt: &want,
+		},
+		{
+			name:         "offset",
+			body:         `{"long_url": "https://example.com", "short_url": "a", "expire_at": "2030-01-02T06:34:05+03:30"}`,
+			wantStatus:   http.StatusOK,
+			wantExpireAt: &want,
+		},
+		{
+			name:         "negative offset",
+			body:         `{"long_url": "https://example.com", "short_url": "a", "expire_at": "2030-01-01T22:04:05-05:00"}`,
+			wantStatus:   http.StatusOK,
+			wantExpireAt: &want,
+		},
+		{
+			name:         "fractional seconds",
+			body:         `{"long_url": "https://example.com", "short_url": "a", "expire_at": "2030-01-02T03:04:04.5Z"}`,
+			wantStatus:   http.StatusOK,
+			wantExpireAt: ptr(want.Add(-500 * time.Millisecond)),
+		},
+		{
+			name: "Unix seconds",
+			body: `{"long_url": "https://example.com", "short_url": "a", "expire_at": "` +
+				strconv.FormatInt(want.Unix(), 10) + `"}`,
+			wantStatus:   http.StatusOK,
+			wantExpireAt: &want,
+		},
+		{
+			name:         "without offset",
+			body:         `{"long_url": "https://example.com", "short_url": "a", "expire_at": "2030-01-02T03:04:05"}`,
+			wantStatus:   http.StatusOK,
+			wantExpireAt: &want,
+		},
+		{
+			name:       "never expires",
+			body:       `{"long_url": "https://example.com", "short_url": "a"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "invalid expiry",
+			body:       `{"long_url": "https://example.com", "short_url": "a", "expire_at": "tomorrow"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "invalid JSON",
+			body:       `{"long_url": `,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "reserved short URL",
+			body:       `{"long_url": "https://example.com", "short_url": "shorten"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+
+			rec := shorten(store, tt.body)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if rec.Code != http.StatusOK {
+				return
+			}
+
+			record, exists, err := store.storage.Get("a")
+			if err != nil || !exists {
+				t.Fatalf("link was not stored: exists=%v, err=%v", exists, err)
+			}
+			switch {
+			case tt.wantExpireAt == nil && record.ExpireAt != nil:
+				t.Errorf("expire_at = %v, want none", record.ExpireAt)
+			case tt.wantExpireAt != nil && record.ExpireAt == nil:
+				t.Errorf("expire_at missing, want %v", tt.wantExpireAt)
+			case tt.wantExpireAt != nil && !record.ExpireAt.Equal(*tt.wantExpireAt):
+				t.Errorf("expire_at = %v, want %v", record.ExpireAt, tt.wantExpireAt)
+			case record.ExpireAt != nil && record.ExpireAt.Location() != time.UTC:
+				t.Errorf("expire_at is stored in %v, want UTC", record.ExpireAt.Location())
+			}
+		})
+	}
+
+	t.Run("existing short URL", func(t *testing.T) {
+		store := newTestStore(t)
+		body := `{"long_url": "https://example.com", "short_url": "a"}`
+		shorten(store, body)
+		if rec := shorten(store, body); rec.Code != http.StatusConflict {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusConflict)
+		}
+	})
+}
+
+func TestCheckValidity(t *testing.T) {
+	now := time.Now()
+	// An hour from now, in a zone far ahead of UTC. Interpreting it in the
+	// wrong zone would make the link expire hours early.
+	inAnHour := now.Add(time.Hour).In(time.FixedZone("", 5*3600+30*60)).Format(time.RFC3339)
+
+	tests := []struct {
+		name   string
+		link   string
+		clicks int
+		want   string
+	}{
+		{
+			name: "no expiry",
+			link: `{"long_url": "https://example.com", "short_url": "a"}`,
+			want: `{"is_valid":true,"is_active":true}`,
+		},
+		{
+			name: "expires later",
+			link: `{"long_url": "https://example.com", "short_url": "a", "expire_at": "` + inAnHour + `"}`,
+			want: `{"is_valid":true,"is_active":true}`,
+		},
+		{
+			name: "expires later in UTC",
+			link: `{"long_url": "https://example.com", "short_url": "a", "expire_at": "` +
+				now.Add(time.Hour).UTC().Format(time.RFC3339) + `"}`,
+			want: `{"is_valid":true,"is_active":true}`,
+		},
+		{
+			name: "expired",
+			link: `{"long_url": "https://example.com", "short_url": "a", "expire_at": "` +
+				strconv.FormatInt(now.Add(-time.Minute).Unix(), 10) + `"}`,
+			want: `{"is_valid":false,"is_active":true}`,
+		},
+		{
+			name: "not active yet",
+			link: `{"long_url": "https://example.com", "short_url": "a", "not_before": "` + inAnHour + `"}`,
+			want: `{"is_valid":true,"is_active":false}`,
+		},
+		{
+			name:   "clicks left",
+			link:   `{"long_url": "https://example.com", "short_url": "a", "max_clicks": 3}`,
+			clicks: 1,
+			want:   `{"is_valid":true,"is_active":true,"remaining_clicks":2}`,
+		},
+		{
+			name:   "clicks used up",
+			link:   `{"long_url": "https://example.com", "short_url": "a", "max_clicks": 1}`,
+			clicks: 1,
+			want:   `{"is_valid":false,"is_active":true,"remaining_clicks":0}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			if rec := shorten(store, tt.link); rec.Code != http.StatusOK {
+				t.Fatalf("shorten: status = %d: %s", rec.Code, rec.Body)
+			}
+			for range tt.clicks {
+				store.Redirect(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+			}
+
+			rec := httptest.NewRecorder()
+			store.CheckValidity(rec, httptest.NewRequest(http.MethodGet, "/valid/a", nil))
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+			}
+			if got := strings.TrimSpace(rec.Body.String()); got != tt.want {
+				t.Errorf("response = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown link", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		newTestStore(t).CheckValidity(rec, httptest.NewRequest(http.MethodGet, "/valid/missing", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want %d", rec.Code, http.StatusNotFound)
+		}
+	})
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// redirect follows the link stored under shortURL through the Redirect
+// handler and returns the response.
+func redirect(store *URLStore, shortURL string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	store.Redirect(rec, httptest.NewRequest(http.MethodGet, "/"+shortURL, nil))
+	return rec
+}
+
+func TestRedirectInactive(t *testing.T) {
+	notBefore := time.Now().Add(time.Hour).UTC()
+	page := []byte("<p>Coming soon</p>")
+
+	tests := []struct {
+		name            string
+		inactive        InactiveResponse
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "default",
+			wantStatus:      http.StatusNotFound,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "URL not active until " + notBefore.Format("2006-01-02 15:04:05 MST") + "\n",
+		},
+		{
+			name:            "configured status",
+			inactive:        InactiveResponse{StatusCode: http.StatusServiceUnavailable},
+			wantStatus:      http.StatusServiceUnavailable,
+			wantContentType: "text/plain; charset=utf-8",
+			wantBody:        "URL not active until " + notBefore.Format("2006-01-02 15:04:05 MST") + "\n",
+		},
+		{
+			name:            "custom page",
+			inactive:        InactiveResponse{StatusCode: http.StatusOK, Page: page},
+			wantStatus:      http.StatusOK,
+			wantContentType: "text/html; charset=utf-8",
+			wantBody:        string(page),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			store.opts.Inactive = tt.inactive
+			record := URLRecord{LongURL: "https://example.com", NotBefore: &notBefore}
+			if err := store.storage.Put("a", record); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := redirect(store, "a")
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if got := rec.Body.String(); got != tt.wantBody {
+				t.Errorf("body = %q, want %q", got, tt.wantBody)
+			}
+			if got := rec.Header().Get("Location"); got != "" {
+				t.Errorf("Location = %q, want none", got)
+			}
+			if _, clicked, err := store.storage.Count("a"); err != nil || clicked {
+				t.Errorf("inactive link was counted: clicked=%v, err=%v", clicked, err)
+			}
+		})
+	}
+}
+
+func TestRedirectClickLimit(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.storage.Put("a", URLRecord{LongURL: "https://example.com", MaxClicks: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		if rec := redirect(store, "a"); rec.Code != http.StatusFound {
+			t.Fatalf("click %d: status = %d, want %d", i+1, rec.Code, http.StatusFound)
+		}
+	}
+	if rec := redirect(store, "a"); rec.Code != http.StatusGone {
+		t.Errorf("click 4: status = %d, want %d", rec.Code, http.StatusGone)
+	}
+	if count, _, err := store.storage.Count("a"); err != nil || count != 3 {
+		t.Errorf("count = %d, %v, want 3", count, err)
+	}
+}
+
+func TestRedirectClickLimitConcurrent(t *testing.T) {
+	const maxClicks, clicks = 10, 100
+	store := newTestStore(t)
+	if err := store.storage.Put("a", URLRecord{LongURL: "https://example.com", MaxClicks: maxClicks}); err != nil {
+		t.Fatal(err)
+	}
+
+	var redirected, gone atomic.Int32
+	var wg sync.WaitGroup
+	for range clicks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			switch rec := redirect(store, "a"); rec.Code {
+			case http.StatusFound:
+				redirected.Add(1)
+			case http.StatusGone:
+				gone.Add(1)
+			default:
+				t.Errorf("status = %d: %s", rec.Code, rec.Body)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if redirected.Load() != maxClicks || gone.Load() != clicks-maxClicks {
+		t.Errorf("%d redirects and %d refusals, want %d and %d",
+			redirected.Load(), gone.Load(), maxClicks, clicks-maxClicks)
+	}
+	if count, _, err := store.storage.Count("a"); err != nil || count != maxClicks {
+		t.Errorf("count = %d, %v, want %d", count, err, maxClicks)
+	}
+}
+
+func TestRedirectType(t *testing.T) {
+	const permanentMaxAge = time.Hour
+
+	tests := []struct {
+		name         string
+		redirectType int
+		expiresIn    time.Duration
+		maxClicks    int
+		wantStatus   int
+		// wantCacheControl is the exact Cache-Control header, unless
+		// wantMaxAge is set, in which case it must be public with a max-age
+		// of at most wantMaxAge and at least a few seconds less, as the
+		// expiry approaches while the test runs.
+		wantCacheControl string
+		wantMaxAge       time.Duration
+	}{
+		{name: "default", wantStatus: http.StatusFound},
+		{name: "found", redirectType: http.StatusFound, wantStatus: http.StatusFound},
+		{name: "temporary", redirectType: http.StatusTemporaryRedirect, wantStatus: http.StatusTemporaryRedirect},
+		{
+			name:         "moved permanently",
+			redirectType: http.StatusMovedPermanently,
+			wantStatus:   http.StatusMovedPermanently,
+			wantMaxAge:   permanentMaxAge,
+		},
+		{
+			name:         "permanent",
+			redirectType: http.StatusPermanentRedirect,
+			wantStatus:   http.StatusPermanentRedirect,
+			wantMaxAge:   permanentMaxAge,
+		},
+		{
+			name:         "permanent expiring after the max age",
+			redirectType: http.StatusPermanentRedirect,
+			expiresIn:    2 * permanentMaxAge,
+			wantStatus:   http.StatusPermanentRedirect,
+			wantMaxAge:   permanentMaxAge,
+		},
+		{
+			name:         "permanent expiring before the max age",
+			redirectType: http.StatusMovedPermanently,
+			expiresIn:    10 * time.Minute,
+			wantStatus:   http.StatusMovedPermanently,
+			wantMaxAge:   10 * time.Minute,
+		},
+		{
+			name:             "permanent with a click limit",
+			redirectType:     http.StatusPermanentRedirect,
+			maxClicks:        5,
+			wantStatus:       http.StatusPermanentRedirect,
+			wantCacheControl: "no-store",
+		},
+		{
+			name:       "temporary with a click limit",
+			maxClicks:  5,
+			wantStatus: http.StatusFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			store.opts.PermanentMaxAge = permanentMaxAge
+			record := URLRecord{LongURL: "https://example.com", MaxClicks: tt.maxClicks, RedirectType: tt.redirectType}
+			if tt.expiresIn != 0 {
+				record.ExpireAt = ptr(time.Now().Add(tt.expiresIn).UTC())
+			}
+			if err := store.storage.Put("a", record); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := redirect(store, "a")
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Location"); got != record.LongURL {
+				t.Errorf("Location = %q, want %q", got, record.LongURL)
+			}
+
+			cacheControl := rec.Header().Get("Cache-Control")
+			if tt.wantMaxAge == 0 {
+				if cacheControl != tt.wantCacheControl {
+					t.Errorf("Cache-Control = %q, want %q", cacheControl, tt.wantCacheControl)
+				}
+				return
+			}
+			var maxAge int
+			if _, err := fmt.Sscanf(cacheControl, "public, max-age=%d", &maxAge); err != nil {
+				t.Fatalf("Cache-Control = %q, want public with a max-age", cacheControl)
+			}
+			if want := int(tt.wantMaxAge.Seconds()); maxAge > want || maxAge < want-5 {
+				t.Errorf("max-age = %d, want %d", maxAge, want)
+			}
+		})
+	}
+}
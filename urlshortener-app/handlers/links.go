@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	errLinkExists   = errors.New("short URL already exists")
+	errLinkNotFound = errors.New("short URL not found")
+
+	// The errors of followLink for links that exist but do not redirect.
+	errLinkExpired       = errors.New("link expired")
+	errLinkInactive      = errors.New("link not active yet")
+	errClickLimitReached = errors.New("link click limit reached")
+)
+
+// fieldError is a request field that failed validation.
+type fieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e *fieldError) Error() string {
+	return e.Field + ": " + e.Reason
+}
+
+const timestampReason = "must be an RFC 3339 timestamp, e.g. 2025-03-01T15:04:05Z, or Unix seconds"
+
+// linkRequest is a link as written by clients of both the legacy routes and
+// the versioned API.
+type linkRequest struct {
+	LongURL string `json:"long_url"`
+	// ExpireAt is an RFC 3339 timestamp with any offset, e.g.
+	// "2025-03-01T15:04:05Z", or Unix seconds.
+	ExpireAt string `json:"expire_at,omitempty"`
+	// NotBefore optionally delays the activation of the link.
+	NotBefore string `json:"not_before,omitempty"`
+	// MaxClicks optionally limits how often the link redirects.
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// RedirectType optionally sets the redirect status code.
+	RedirectType int `json:"redirect_type,omitempty"`
+	// ShortURL optionally requests a specific short path, e.g. a vanity
+	// alias or a link the store has lost. It is ignored on updates.
+	ShortURL string `json:"short_url,omitempty"`
+}
+
+// record parses and validates the request into the record it describes.
+func (req linkRequest) record() (URLRecord, error) {
+	expireAt, err := parseTimestamp(req.ExpireAt)
+	if err != nil {
+		return URLRecord{}, &fieldError{"expire_at", timestampReason}
+	}
+	notBefore, err := parseTimestamp(req.NotBefore)
+	if err != nil {
+		return URLRecord{}, &fieldError{"not_before", timestampReason}
+	}
+
+	record := URLRecord{
+		LongURL:      req.LongURL,
+		ExpireAt:     expireAt,
+		NotBefore:    notBefore,
+		MaxClicks:    req.MaxClicks,
+		RedirectType: req.RedirectType,
+	}
+	return record, validateRecord(record)
+}
+
+// validateRecord checks the fields of a record that do not depend on how it
+// was sent.
+func validateRecord(record URLRecord) error {
+	switch {
+	case record.LongURL == "":
+		return &fieldError{"long_url", "is required"}
+	case record.MaxClicks < 0:
+		return &fieldError{"max_clicks", "must not be negative"}
+	case !validRedirectType(record.RedirectType):
+		return &fieldError{"redirect_type", "must be 301, 302, 307 or 308"}
+	case record.NotBefore != nil && record.ExpireAt != nil && !record.NotBefore.Before(*record.ExpireAt):
+		return &fieldError{"not_before", "must be before expire_at"}
+	}
+	return nil
+}
+
+// linkState is what a link currently does when followed.
+type linkState struct {
+	IsValid  bool `json:"is_valid"`
+	IsActive bool `json:"is_active"`
+	// RemainingClicks is only reported for links with a click limit.
+	RemainingClicks *int `json:"remaining_clicks,omitempty"`
+}
+
+// stateOf returns the state of a link with the given click count at now.
+func stateOf(record URLRecord, count int, now time.Time) linkState {
+	state := linkState{
+		IsValid:  record.ExpireAt == nil || now.Before(*record.ExpireAt),
+		IsActive: record.NotBefore == nil || !now.Before(*record.NotBefore),
+	}
+	if record.MaxClicks > 0 {
+		remaining := max(record.MaxClicks-count, 0)
+		state.RemainingClicks = &remaining
+		if remaining == 0 {
+			state.IsValid = false
+		}
+	}
+	return state
+}
+
+// createLink stores record under shortURL, or under a generated short URL if
+// it is empty, and returns the short URL.
+func (u *URLStore) createLink(shortURL string, record URLRecord) (string, error) {
+	if shortURL != "" && !validShortURL(shortURL) {
+		return "", &fieldError{"short_url", "is reserved or contains invalid characters"}
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if shortURL == "" {
+		var err error
+		if shortURL, err = u.generateShortURL(); err != nil {
+			return "", err
+		}
+	} else {
+		_, exists, err := u.storage.Get(shortURL)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "", errLinkExists
+		}
+	}
+	return shortURL, u.storage.Put(shortURL, record)
+}
+
+// followLink counts a click on the link stored under shortURL and returns its
+// record and new click count. Links that do not redirect right now fail with
+// errLinkExpired, errLinkInactive or errClickLimitReached, along with their
+// record. The click limit is checked and the count incremented under the same
+// lock, so concurrent clicks cannot exceed it.
+func (u *URLStore) followLink(shortURL string) (URLRecord, int, error) {
+	record, count, err := u.countClick(shortURL)
+	if err == nil {
+		u.opts.Events.Click(shortURL, count)
+	}
+	return record, count, err
+}
+
+func (u *URLStore) countClick(shortURL string) (URLRecord, int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	record, exists, err := u.storage.Get(shortURL)
+	if err != nil {
+		return URLRecord{}, 0, err
+	}
+	if !exists {
+		return URLRecord{}, 0, errLinkNotFound
+	}
+	if record.ExpireAt != nil && time.Until(*record.ExpireAt) < 0 {
+		return record, 0, errLinkExpired
+	}
+	if record.NotBefore != nil && time.Now().Before(*record.NotBefore) {
+		return record, 0, errLinkInactive
+	}
+	count, _, err := u.storage.Count(shortURL)
+	if err != nil {
+		return URLRecord{}, 0, err
+	}
+	if record.MaxClicks > 0 && count >= record.MaxClicks {
+		return record, count, errClickLimitReached
+	}
+	if err := u.storage.IncrementCount(shortURL); err != nil {
+		return URLRecord{}, 0, err
+	}
+	return record, count + 1, nil
+}
+
+// getLink returns the record stored under shortURL and its click count.
+func (u *URLStore) getLink(shortURL string) (URLRecord, int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	record, exists, err := u.storage.Get(shortURL)
+	if err != nil {
+		return URLRecord{}, 0, err
+	}
+	if !exists {
+		return URLRecord{}, 0, errLinkNotFound
+	}
+	// Links that were never clicked have no count yet.
+	count, _, err := u.storage.Count(shortURL)
+	return record, count, err
+}
+
+// updateLink applies update to the record stored under shortURL and stores
+// the result if it is valid. The click count is kept.
+func (u *URLStore) updateLink(shortURL string, update func(*URLRecord) error) (URLRecord, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	record, exists, err := u.storage.Get(shortURL)
+	if err != nil {
+		return URLRecord{}, err
+	}
+	if !exists {
+		return URLRecord{}, errLinkNotFound
+	}
+	if err := update(&record); err != nil {
+		return URLRecord{}, err
+	}
+	if err := validateRecord(record); err != nil {
+		return URLRecord{}, err
+	}
+	return record, u.storage.Put(shortURL, record)
+}
+
+// deleteLink removes the link stored under shortURL and its click count.
+func (u *URLStore) deleteLink(shortURL string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	exists, err := u.storage.Delete(shortURL)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errLinkNotFound
+	}
+	return nil
+}
+
+// listLinks returns up to limit links after the short URL after, with their
+// click counts.
+func (u *URLStore) listLinks(after string, limit int) ([]StoredLink, []int, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	links, err := u.storage.List(after, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := make([]int, len(links))
+	for i, link := range links {
+		if counts[i], _, err = u.storage.Count(link.ShortURL); err != nil {
+			return nil, nil, fmt.Errorf("counting clicks of %s: %w", link.ShortURL, err)
+		}
+	}
+	return links, counts, nil
+}
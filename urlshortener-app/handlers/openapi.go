@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// OpenAPI is the OpenAPI 3 document describing every route of the shortener.
+// The operator's test suite checks its client against it, so keep it in sync
+// with the handlers.
+//
+//go:embed openapi.json
+var OpenAPI []byte
+
+// ServeOpenAPI serves the OpenAPI document.
+func ServeOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(OpenAPI)
+}
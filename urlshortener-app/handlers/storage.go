@@ -0,0 +1,31 @@
+package handlers
+
+// Storage persists short links and their click counts. Implementations must
+// be safe for concurrent use; URLStore serializes compound operations on top
+// of them.
+type Storage interface {
+	// Get returns the record stored under shortURL and whether it exists.
+	Get(shortURL string) (URLRecord, bool, error)
+	// Put creates or replaces the record stored under shortURL.
+	Put(shortURL string, record URLRecord) error
+	// Delete removes the record and click count of shortURL and reports
+	// whether it existed.
+	Delete(shortURL string) (bool, error)
+	// Count returns the click count of shortURL and whether it was ever
+	// clicked.
+	Count(shortURL string) (int, bool, error)
+	// List returns up to limit records ordered by short URL, starting with
+	// the first short URL after the given one. An empty after starts at the
+	// beginning.
+	List(after string, limit int) ([]StoredLink, error)
+	// IncrementCount adds one click to shortURL.
+	IncrementCount(shortURL string) error
+	// Close releases any resources held by the storage.
+	Close() error
+}
+
+// StoredLink is a record together with the short URL it is stored under.
+type StoredLink struct {
+	ShortURL string
+	Record   URLRecord
+}
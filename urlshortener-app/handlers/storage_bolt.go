@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	linksBucket  = []byte("links")
+	countsBucket = []byte("counts")
+)
+
+// BoltStorage keeps links in a single bbolt database file, so they survive
+// restarts as long as the file lives on a persistent volume.
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+func NewBoltStorage(path string) (*BoltStorage, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(linksBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(countsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+func (b *BoltStorage) Get(shortURL string) (URLRecord, bool, error) {
+	var record URLRecord
+	var exists bool
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(linksBucket).Get([]byte(shortURL))
+		if data == nil {
+			return nil
+		}
+		exists = true
+		return json.Unmarshal(data, &record)
+	})
+	return record, exists, err
+}
+
+func (b *BoltStorage) Put(shortURL string, record URLRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(linksBucket).Put([]byte(shortURL), data)
+	})
+}
+
+func (b *BoltStorage) Delete(shortURL string) (bool, error) {
+	var exists bool
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		links := tx.Bucket(linksBucket)
+		exists = links.Get([]byte(shortURL)) != nil
+		if err := links.Delete([]byte(shortURL)); err != nil {
+			return err
+		}
+		return tx.Bucket(countsBucket).Delete([]byte(shortURL))
+	})
+	return exists, err
+}
+
+func (b *BoltStorage) Count(shortURL string) (int, bool, error) {
+	var count int
+	var exists bool
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(countsBucket).Get([]byte(shortURL))
+		if data == nil {
+			return nil
+		}
+		exists = true
+		count = int(binary.BigEndian.Uint64(data))
+		return nil
+	})
+	return count, exists, err
+}
+
+func (b *BoltStorage) List(after string, limit int) ([]StoredLink, error) {
+	var links []StoredLink
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(linksBucket).Cursor()
+		k, v := c.Seek([]byte(after))
+		if k != nil && string(k) == after {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(links) < limit; k, v = c.Next() {
+			var record URLRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			links = append(links, StoredLink{ShortURL: string(k), Record: record})
+		}
+		return nil
+	})
+	return links, err
+}
+
+func (b *BoltStorage) IncrementCount(shortURL string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		counts := tx.Bucket(countsBucket)
+
+		var count uint64
+		if data := counts.Get([]byte(shortURL)); data != nil {
+			count = binary.BigEndian.Uint64(data)
+		}
+
+		buf := make([]byte, 8)
+		binary.BigEndian.PutUint64(buf, count+1)
+		return counts.Put([]byte(shortURL), buf)
+	})
+}
+
+func (b *BoltStorage) Close() error {
+	return b.db.Close()
+}
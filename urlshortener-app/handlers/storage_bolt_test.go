@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestBoltStorage opens a database in a temporary directory and returns
+// its path along with it.
+func newTestBoltStorage(t *testing.T) (*BoltStorage, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "links.db")
+	storage, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage, path
+}
+
+func TestBoltStorage(t *testing.T) {
+	storage, _ := newTestBoltStorage(t)
+	expireAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if _, exists, err := storage.Get("a"); err != nil || exists {
+		t.Fatalf("Get of a missing link = %v, %v, want false, nil", exists, err)
+	}
+
+	record := URLRecord{LongURL: "https://example.com", ExpireAt: &expireAt}
+	if err := storage.Put("a", record); err != nil {
+		t.Fatal(err)
+	}
+	got, exists, err := storage.Get("a")
+	if err != nil || !exists || !reflect.DeepEqual(got, record) {
+		t.Fatalf("Get = %+v, %v, %v, want %+v", got, exists, err, record)
+	}
+
+	updated := URLRecord{LongURL: "https://example.com/new"}
+	if err := storage.Put("a", updated); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, _ := storage.Get("a"); !reflect.DeepEqual(got, updated) {
+		t.Fatalf("Get after update = %+v, want %+v", got, updated)
+	}
+
+	if _, clicked, err := storage.Count("a"); err != nil || clicked {
+		t.Fatalf("Count before any click = %v, %v, want false, nil", clicked, err)
+	}
+	for range 2 {
+		if err := storage.IncrementCount("a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if count, clicked, err := storage.Count("a"); err != nil || !clicked || count != 2 {
+		t.Fatalf("Count = %d, %v, %v, want 2, true, nil", count, clicked, err)
+	}
+
+	if existed, err := storage.Delete("a"); err != nil || !existed {
+		t.Fatalf("Delete = %v, %v, want true, nil", existed, err)
+	}
+	if _, exists, _ := storage.Get("a"); exists {
+		t.Error("link still exists after Delete")
+	}
+	if _, clicked, _ := storage.Count("a"); clicked {
+		t.Error("click count still exists after Delete")
+	}
+	if existed, err := storage.Delete("a"); err != nil || existed {
+		t.Fatalf("second Delete = %v, %v, want false, nil", existed, err)
+	}
+}
+
+func TestBoltStorageList(t *testing.T) {
+	storage, _ := newTestBoltStorage(t)
+	for _, shortURL := range []string{"c", "a", "b"} {
+		if err := storage.Put(shortURL, URLRecord{LongURL: "https://example.com/" + shortURL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		after string
+		limit int
+		want  []string
+	}{
+		{name: "from the start", limit: 10, want: []string{"a", "b", "c"}},
+		{name: "first page", limit: 2, want: []string{"a", "b"}},
+		{name: "after a stored link", after: "a", limit: 10, want: []string{"b", "c"}},
+		{name: "after a missing link", after: "aa", limit: 10, want: []string{"b", "c"}},
+		{name: "past the end", after: "c", limit: 10},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links, err := storage.List(tt.after, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, link := range links {
+				got = append(got, link.ShortURL)
+				if link.Record.LongURL != "https://example.com/"+link.ShortURL {
+					t.Errorf("record of %s = %+v", link.ShortURL, link.Record)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoltStorageSurvivesReopen(t *testing.T) {
+	storage, path := newTestBoltStorage(t)
+	record := URLRecord{LongURL: "https://example.com"}
+	if err := storage.Put("a", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.IncrementCount("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewBoltStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	got, exists, err := reopened.Get("a")
+	if err != nil || !exists || !reflect.DeepEqual(got, record) {
+		t.Fatalf("Get after reopen = %+v, %v, %v, want %+v", got, exists, err, record)
+	}
+	if count, _, err := reopened.Count("a"); err != nil || count != 1 {
+		t.Fatalf("Count after reopen = %d, %v, want 1", count, err)
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"slices"
+	"sync"
+)
+
+// MemoryStorage keeps links in process memory. Everything is lost when the
+// process exits.
+type MemoryStorage struct {
+	mu    sync.RWMutex
+	store map[string]URLRecord
+	count map[string]int
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{
+		store: make(map[string]URLRecord),
+		count: make(map[string]int),
+	}
+}
+
+func (m *MemoryStorage) Get(shortURL string) (URLRecord, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	record, exists := m.store[shortURL]
+	return record, exists, nil
+}
+
+func (m *MemoryStorage) Put(shortURL string, record URLRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.store[shortURL] = record
+	return nil
+}
+
+func (m *MemoryStorage) Delete(shortURL string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, exists := m.store[shortURL]
+	delete(m.store, shortURL)
+	delete(m.count, shortURL)
+	return exists, nil
+}
+
+func (m *MemoryStorage) Count(shortURL string) (int, bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count, exists := m.count[shortURL]
+	return count, exists, nil
+}
+
+// List sorts all short URLs on every call, which is fine for the amount of
+// links a single process can hold.
+func (m *MemoryStorage) List(after string, limit int) ([]StoredLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]string, 0, len(m.store))
+	for shortURL := range m.store {
+		if shortURL > after {
+			keys = append(keys, shortURL)
+		}
+	}
+	slices.Sort(keys)
+
+	links := make([]StoredLink, 0, min(limit, len(keys)))
+	for _, shortURL := range keys[:min(limit, len(keys))] {
+		links = append(links, StoredLink{ShortURL: shortURL, Record: m.store[shortURL]})
+	}
+	return links, nil
+}
+
+func (m *MemoryStorage) IncrementCount(shortURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.count[shortURL]++
+	return nil
+}
+
+func (m *MemoryStorage) Close() error {
+	return nil
+}
@@ -1,17 +1,64 @@
 package handlers
 
 import (
-	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
-var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+// reservedShortURLs are path segments used by the API itself, which would
+// shadow or be shadowed by a link of the same name.
+var reservedShortURLs = map[string]bool{
+	"shorten":      true,
+	"count":        true,
+	"valid":        true,
+	"links":        true,
+	"events":       true,
+	"healthz":      true,
+	"api":          true,
+	"openapi.json": true,
+}
+
+// validShortURL reports whether a requested short path can be served by the
+// redirect route.
+func validShortURL(shortURL string) bool {
+	if reservedShortURLs[strings.ToLower(shortURL)] {
+		return false
+	}
+	return !strings.ContainsAny(shortURL, "/?#")
+}
+
+// validRedirectType reports whether status is a redirect status code a link
+// may use. Zero selects the default.
+func validRedirectType(status int) bool {
+	switch status {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
 
-func generateShortURL() string {
-	rand.Seed(time.Now().UnixNano())
-	b := make([]rune, 4)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+// parseTimestamp parses an optional time field of a request and returns it
+// in UTC. It accepts RFC 3339 timestamps with any offset and fractional
+// seconds, Unix seconds, and, for older clients, timestamps without an offset,
+// which are taken to be UTC. An empty value yields nil.
+func parseTimestamp(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t := time.Unix(seconds, 0).UTC()
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		var naiveErr error
+		if t, naiveErr = time.Parse("2006-01-02T15:04:05", value); naiveErr != nil {
+			return nil, err
+		}
 	}
-	return string(b)
+	t = t.UTC()
+	return &t, nil
 }
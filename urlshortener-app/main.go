@@ -1,20 +1,172 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"time"
+	// The runtime image has no zoneinfo, so embed it for -display-timezone.
+	_ "time/tzdata"
 	"urlshortener/handlers"
+
+	"google.golang.org/grpc"
 )
 
 func main() {
-	store := handlers.NewURLStore()
+	var storageBackend, storagePath string
+	var codeAlphabet string
+	var codeLength int
+	var eventFlushInterval time.Duration
+	var inactiveStatus int
+	var inactivePage string
+	var permanentMaxAge time.Duration
+	var displayTimezone string
+	var grpcAddr string
+	var apiKeysFile string
+	flag.StringVar(&storageBackend, "storage", envOrDefault("STORAGE_BACKEND", "memory"),
+		"Where links are kept: memory or bolt.")
+	flag.StringVar(&storagePath, "storage-path", envOrDefault("STORAGE_PATH", "/data/urlshortener.db"),
+		"Path of the database file used by the bolt storage.")
+	flag.StringVar(&codeAlphabet, "code-alphabet", envOrDefault("CODE_ALPHABET", handlers.Base62Alphabet),
+		"Characters used in generated short URLs.")
+	flag.IntVar(&codeLength, "code-length", envIntOrDefault("CODE_LENGTH", 6),
+		"Initial length of generated short URLs. It grows automatically when the keyspace gets crowded.")
+	flag.DurationVar(&eventFlushInterval, "event-flush-interval", envDurationOrDefault("EVENT_FLUSH_INTERVAL", time.Second),
+		"How long clicks are batched before they are sent to the subscribers of /events.")
+	flag.IntVar(&inactiveStatus, "inactive-status", envIntOrDefault("INACTIVE_STATUS", http.StatusNotFound),
+		"Status code answered for links whose not_before time has not come yet.")
+	flag.StringVar(&inactivePage, "inactive-page", envOrDefault("INACTIVE_PAGE", ""),
+		"Path of an HTML page served for links that are not active yet. A plain text message is sent if empty.")
+	flag.DurationVar(&permanentMaxAge, "permanent-redirect-max-age",
+		envDurationOrDefault("PERMANENT_REDIRECT_MAX_AGE", 24*time.Hour),
+		"How long clients may cache 301 and 308 redirects. Shorter for links that expire sooner.")
+	flag.StringVar(&displayTimezone, "display-timezone", envOrDefault("DISPLAY_TIMEZONE", "UTC"),
+		"Time zone of times in messages and logs, e.g. Asia/Tehran. The API always uses UTC.")
+	flag.StringVar(&grpcAddr, "grpc-addr", envOrDefault("GRPC_ADDR", ":50051"),
+		"Address the gRPC API listens on. The gRPC API is disabled if empty.")
+	flag.StringVar(&apiKeysFile, "api-keys-file", envOrDefault("API_KEYS_FILE", ""),
+		"File with the API keys, one per line, required on the management routes. They are open to all if empty.")
+	flag.Parse()
+
+	displayZone, err := time.LoadLocation(displayTimezone)
+	if err != nil {
+		log.Fatalln("invalid display time zone:", err)
+	}
+
+	generator, err := handlers.NewCodeGenerator(codeAlphabet, codeLength)
+	if err != nil {
+		log.Fatalln("invalid short URL settings:", err)
+	}
+
+	storage, err := newStorage(storageBackend, storagePath)
+	if err != nil {
+		log.Fatalln("unable to open storage:", err)
+	}
+	defer storage.Close()
+
+	events := handlers.NewEventFeed()
+	go events.Run(eventFlushInterval, nil)
 
-	http.HandleFunc("/shorten", store.ShortenURL)
-	http.HandleFunc("/count/", store.GetCount)
-	http.HandleFunc("/valid/", store.CheckValidity)
+	inactive := handlers.InactiveResponse{StatusCode: inactiveStatus}
+	if inactivePage != "" {
+		if inactive.Page, err = os.ReadFile(inactivePage); err != nil {
+			log.Fatalln("unable to read inactive page:", err)
+		}
+	}
+
+	var apiKeys *handlers.APIKeys
+	if apiKeysFile != "" {
+		if apiKeys, err = handlers.LoadAPIKeys(apiKeysFile); err != nil {
+			log.Fatalln("unable to load API keys:", err)
+		}
+		go apiKeys.Run(apiKeysReloadInterval, nil)
+	} else {
+		log.Println("No API keys configured, the management routes are open to all")
+	}
+
+	store := handlers.NewURLStore(storage, generator, handlers.Options{
+		Events:          events,
+		Inactive:        inactive,
+		PermanentMaxAge: permanentMaxAge,
+		DisplayZone:     displayZone,
+		APIKeys:         apiKeys,
+	})
+
+	// Only the redirects and the API description are public.
+	http.Handle("/shorten", store.RequireAPIKey(http.HandlerFunc(store.ShortenURL)))
+	http.Handle("/count/", store.RequireAPIKey(http.HandlerFunc(store.GetCount)))
+	http.Handle("/valid/", store.RequireAPIKey(http.HandlerFunc(store.CheckValidity)))
+	http.Handle("GET /links/{path}", store.RequireAPIKey(http.HandlerFunc(store.GetURL)))
+	http.Handle("PUT /links/{path}", store.RequireAPIKey(http.HandlerFunc(store.UpdateURL)))
+	http.Handle("DELETE /links/{path}", store.RequireAPIKey(http.HandlerFunc(store.DeleteURL)))
+	http.Handle("GET /events", store.RequireAPIKey(events))
+	store.RegisterAPI(http.DefaultServeMux)
+	http.HandleFunc("GET /openapi.json", handlers.ServeOpenAPI)
 	http.HandleFunc("/", store.Redirect)
 
+	if grpcAddr != "" {
+		listener, err := net.Listen("tcp", grpcAddr)
+		if err != nil {
+			log.Fatalln("unable to listen for gRPC:", err)
+		}
+		grpcServer := grpc.NewServer()
+		store.RegisterGRPC(grpcServer)
+		log.Println("gRPC API listening on", grpcAddr)
+		go grpcServer.Serve(listener)
+	}
+
 	log.Println("start listening on port 8080")
 
 	http.ListenAndServe(":8080", nil)
 }
+
+// apiKeysReloadInterval is how often the API keys file is read again. The
+// kubelet takes about a minute to update a mounted Secret anyway.
+const apiKeysReloadInterval = 30 * time.Second
+
+func newStorage(backend, path string) (handlers.Storage, error) {
+	switch backend {
+	case "memory":
+		return handlers.NewMemoryStorage(), nil
+	case "bolt":
+		log.Println("using bolt storage at", path)
+		return handlers.NewBoltStorage(path)
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+func envOrDefault(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return def
+}
+
+func envIntOrDefault(key string, def int) int {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", key, value, err)
+	}
+	return n
+}
+
+func envDurationOrDefault(key string, def time.Duration) time.Duration {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s %q: %v", key, value, err)
+	}
+	return d
+}